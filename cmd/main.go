@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	_ "github.com/vkukul/messaging-system/docs"
 	"github.com/vkukul/messaging-system/internal/api"
+	"github.com/vkukul/messaging-system/internal/logging"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/internal/service"
+	"github.com/vkukul/messaging-system/internal/tracing"
 	"github.com/vkukul/messaging-system/pkg/database"
 	"github.com/vkukul/messaging-system/pkg/redis"
 )
@@ -18,25 +26,93 @@ import (
 // @BasePath        /api/v1
 // @schemes         http
 
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+
+// shutdownTracing flushes buffered spans. fatal calls it because os.Exit
+// skips deferred calls.
+var shutdownTracing = func(context.Context) error { return nil }
+
 func main() {
+	// Initialize structured logging first so startup errors are structured too
+	if err := logging.Init(); err != nil {
+		log.Fatal("Failed to initialize logging:", err)
+	}
+
+	// Initialize tracing before anything that creates spans
+	shutdown, err := tracing.Init(context.Background())
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	shutdownTracing = shutdown
+	defer shutdownTracing(context.Background())
+
 	// Initialize database
 	if err := database.InitDB(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		fatal("Failed to initialize database", err)
+	}
+
+	// Make sure a fresh deployment has a key to create the others with
+	if bootstrapKey := os.Getenv("API_BOOTSTRAP_KEY"); bootstrapKey != "" {
+		if err := service.NewAPIKeyService().EnsureBootstrapKey(bootstrapKey); err != nil {
+			fatal("Failed to create bootstrap API key", err)
+		}
 	}
 
 	// Initialize Redis (bonus feature)
 	if err := redis.InitRedis(); err != nil {
-		log.Printf("Warning: Failed to initialize Redis (bonus feature): %v", err)
+		slog.Warn("Failed to initialize Redis (bonus feature)", "error", err)
+	}
+
+	// Load outbound gateway configuration
+	if err := provider.Init(); err != nil {
+		fatal("Failed to load provider configuration", err)
 	}
+	if err := service.InitCircuitBreakers(); err != nil {
+		fatal("Failed to configure provider circuit breakers", err)
+	}
+
+	// Start relaying outbox events to the configured sink
+	sink, err := service.NewOutboxSinkFromEnv()
+	if err != nil {
+		fatal("Failed to configure outbox sink", err)
+	}
+	go service.NewOutboxRelay(sink).Run(context.Background())
+
+	// Start delivering status-change webhooks to clients
+	dispatcher, err := service.NewWebhookDispatcher()
+	if err != nil {
+		fatal("Failed to configure webhook dispatcher", err)
+	}
+	go dispatcher.Run(context.Background())
 
-	// Initialize Gin router
-	r := gin.Default()
+	// Start resolving sends whose outcome was never recorded
+	go service.NewReconciler().Run(context.Background())
+
+	// Start falling back to the next channel when no delivery receipt arrives
+	go service.NewFallbackMonitor().Run(context.Background())
+
+	// Initialize Gin router; requests are logged by our structured logger
+	r := gin.New()
+	r.Use(gin.Recovery())
 
 	// Setup API routes
 	api.SetupRoutes(r)
 
 	// Start the server
 	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+		fatal("Failed to start server", err)
+	}
+}
+
+// fatal logs a startup error, flushes the spans recorded so far and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
 	}
+	cancel()
+	os.Exit(1)
 }
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+const (
+	// APIKeyHeader carries the API key; "Authorization: Bearer <key>" also works
+	APIKeyHeader = "X-API-Key"
+)
+
+// authenticate rejects requests without an active API key and stores the
+// key in the Gin context for requireScope, and its tenant for the handlers
+func authenticate(keys *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := keys.Authenticate(c.Request.Context(), presentedKey(c.Request))
+		if err != nil {
+			if errors.Is(err, service.ErrUnauthenticated) {
+				c.Header("WWW-Authenticate", `Bearer realm="api"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, handlers.Response{Message: err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, handlers.Response{Message: err.Error()})
+			return
+		}
+
+		c.Set(handlers.APIKeyContextKey, key)
+		c.Set(handlers.TenantContextKey, key.TenantID)
+		c.Next()
+	}
+}
+
+// requireScope rejects requests whose key was not granted scope. It must run
+// after authenticate.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(handlers.APIKeyContextKey)
+		if !ok || !service.HasScope(value.(*models.APIKey), scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, handlers.Response{Message: "API key lacks the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// presentedKey returns the key from X-API-Key or a bearer Authorization header
+func presentedKey(req *http.Request) string {
+	if key := req.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	auth := req.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
+
+// publicEndpoints reads which endpoints outside /api/v1 are served without a
+// key, from a comma separated PUBLIC_ENDPOINTS list of swagger, health and
+// metrics
+func publicEndpoints() map[string]bool {
+	public := make(map[string]bool)
+	for _, name := range strings.Split(getEnv("PUBLIC_ENDPOINTS", "swagger,health,metrics"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			public[name] = true
+		}
+	}
+	return public
+}
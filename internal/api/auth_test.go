@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func TestPresentedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "X-API-Key header", headers: map[string]string{APIKeyHeader: "msk_abc"}, want: "msk_abc"},
+		{name: "Bearer token", headers: map[string]string{"Authorization": "Bearer msk_abc"}, want: "msk_abc"},
+		{name: "Lowercase bearer", headers: map[string]string{"Authorization": "bearer msk_abc"}, want: "msk_abc"},
+		{name: "Basic auth ignored", headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, want: ""},
+		{name: "No credentials", headers: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			assert.Equal(t, tt.want, presentedKey(req))
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Setenv("PUBLIC_ENDPOINTS", "health")
+
+	router := setupTestRouter()
+	readKey := testAPIKey(t, models.ScopeMessagesRead)
+
+	revoked, revokedRaw, err := service.NewAPIKeyService().CreateKey(models.DefaultTenantID, "revoked", []string{models.ScopeMessagesRead}, nil, 0)
+	assert.NoError(t, err)
+	defer database.DB.Unscoped().Delete(revoked)
+	assert.NoError(t, service.NewAPIKeyService().RevokeKey(models.DefaultTenantID, revoked.ID))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{name: "Missing key", method: "GET", path: "/api/v1/messages/sent", key: "", wantStatus: http.StatusUnauthorized},
+		{name: "Unknown key", method: "GET", path: "/api/v1/messages/sent", key: "msk_unknown", wantStatus: http.StatusUnauthorized},
+		{name: "Revoked key", method: "GET", path: "/api/v1/messages/sent", key: revokedRaw, wantStatus: http.StatusUnauthorized},
+		{name: "Scope granted", method: "GET", path: "/api/v1/messages/sent", key: readKey, wantStatus: http.StatusOK},
+		{name: "Scope missing", method: "POST", path: "/api/v1/messages/stop", key: readKey, wantStatus: http.StatusForbidden},
+		{name: "Key management needs keys:admin", method: "GET", path: "/api/v1/keys", key: readKey, wantStatus: http.StatusForbidden},
+		{name: "Public health endpoint", method: "GET", path: "/healthz", key: "", wantStatus: http.StatusOK},
+		{name: "Private metrics endpoint", method: "GET", path: "/metrics", key: "", wantStatus: http.StatusUnauthorized},
+		{name: "Callbacks use signatures instead", method: "POST", path: "/api/v1/callbacks/dlr/unknown", key: "", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
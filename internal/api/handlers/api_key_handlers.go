@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type APIKeyHandlers struct {
+	apiKeyService *service.APIKeyService
+}
+
+// CreateAPIKeyRequest represents a request to issue an API key
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required" enums:"messages:write,messages:read,processor:admin,keys:admin,tenants:admin"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// DailyMessageQuota caps messages created per UTC day; 0 uses the default
+	DailyMessageQuota int64 `json:"daily_message_quota,omitempty"`
+}
+
+// APIKey represents an issued API key. The key itself is only returned on creation.
+type APIKey struct {
+	ID         uint     `json:"id"`
+	TenantID   uint     `json:"tenant_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Key        string   `json:"key,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	UsageCount int64    `json:"usage_count"`
+	// DailyMessageQuota is 0 when the key uses the default quota
+	DailyMessageQuota int64  `json:"daily_message_quota"`
+	CreatedAt         string `json:"created_at"`
+}
+
+func NewAPIKeyHandlers(apiKeyService *service.APIKeyService) *APIKeyHandlers {
+	return &APIKeyHandlers{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Issue an API key for the caller's tenant with the given scopes, which the caller's key must hold. processor:admin and tenants:admin are only available to the default tenant. The key is only shown in this response.
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        key  body      CreateAPIKeyRequest  true  "Key to issue"
+// @Success      201  {object}  APIKey
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /keys [post]
+func (h *APIKeyHandlers) CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	createAPIKey(c, h.apiKeyService, tenantID(c), &req)
+}
+
+// createAPIKey issues a key for a tenant and writes the response. The new key
+// may only hold scopes the caller's own key holds.
+func createAPIKey(c *gin.Context, apiKeyService *service.APIKeyService, tenantID uint, req *CreateAPIKeyRequest) {
+	issuer, ok := apiKey(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, Response{Message: service.ErrScopeNotGranted.Error()})
+		return
+	}
+	if err := service.CanGrant(issuer, req.Scopes); err != nil {
+		c.JSON(http.StatusForbidden, Response{Message: err.Error()})
+		return
+	}
+
+	key, raw, err := apiKeyService.CreateKey(tenantID, req.Name, req.Scopes, req.ExpiresAt, req.DailyMessageQuota)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+
+	resp := toAPIKey(key)
+	resp.Key = raw
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListAPIKeys godoc
+// @Summary      List API keys
+// @Description  Get the API keys of the caller's tenant with their scopes and usage, including revoked keys
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   APIKey
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /keys [get]
+func (h *APIKeyHandlers) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListKeys(tenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+
+	resp := make([]APIKey, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, toAPIKey(&keys[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Revoke an API key. Requests using it are rejected from then on.
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {object}  Response
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /keys/{id} [delete]
+func (h *APIKeyHandlers) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid API key id"})
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(tenantID(c), uint(id)); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Message: "API key revoked"})
+}
+
+func toAPIKey(key *models.APIKey) APIKey {
+	resp := APIKey{
+		ID:                key.ID,
+		TenantID:          key.TenantID,
+		Name:              key.Name,
+		Prefix:            key.Prefix,
+		Scopes:            strings.Split(key.Scopes, ","),
+		UsageCount:        key.UsageCount,
+		CreatedAt:         key.CreatedAt.Format(time.RFC3339),
+		DailyMessageQuota: key.DailyMessageQuota,
+	}
+	if key.ExpiresAt != nil {
+		resp.ExpiresAt = key.ExpiresAt.Format(time.RFC3339)
+	}
+	if key.RevokedAt != nil {
+		resp.RevokedAt = key.RevokedAt.Format(time.RFC3339)
+	}
+	if key.LastUsedAt != nil {
+		resp.LastUsedAt = key.LastUsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type CallbackHandlers struct {
+	callbackService *service.CallbackService
+}
+
+// DeliveryReceiptRequest represents a delivery receipt sent by a gateway
+type DeliveryReceiptRequest struct {
+	MessageID   string `json:"message_id"`
+	Status      string `json:"status" enums:"delivered,undeliverable,expired"`
+	ErrorCode   string `json:"error_code,omitempty"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+}
+
+// InboundMessageRequest represents a reply forwarded by a gateway
+type InboundMessageRequest struct {
+	MessageID  string `json:"message_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Content    string `json:"content"`
+	ReceivedAt string `json:"received_at,omitempty"`
+}
+
+func NewCallbackHandlers(callbackService *service.CallbackService) *CallbackHandlers {
+	return &CallbackHandlers{
+		callbackService: callbackService,
+	}
+}
+
+// callbackError maps callback service errors onto HTTP responses
+func callbackError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+	case errors.Is(err, service.ErrInvalidSignature):
+		c.JSON(http.StatusUnauthorized, Response{Message: err.Error()})
+	case errors.Is(err, service.ErrInvalidReceipt), errors.Is(err, service.ErrInvalidInboundMessage):
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+	}
+}
+
+// DeliveryReceipt godoc
+// @Summary      Receive a delivery receipt
+// @Description  Accept an asynchronous delivery receipt from a gateway. The request must be signed with the provider's inbound secret.
+// @Tags         Callbacks
+// @Accept       json
+// @Produce      json
+// @Param        provider               path      string                  true  "Provider name"
+// @Param        X-Signature-Timestamp  header    string                  true  "Unix timestamp used in the signature"
+// @Param        X-Signature            header    string                  true  "Hex HMAC-SHA256 of <timestamp>.<body>"
+// @Param        receipt                body      DeliveryReceiptRequest  true  "Delivery receipt"
+// @Success      202                    {object}  Response
+// @Failure      400                    {object}  Response
+// @Failure      401                    {object}  Response
+// @Failure      404                    {object}  Response
+// @Failure      500                    {object}  Response
+// @Router       /callbacks/dlr/{provider} [post]
+func (h *CallbackHandlers) DeliveryReceipt(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	_, err = h.callbackService.HandleDeliveryReceipt(
+		c.Request.Context(),
+		c.Param("provider"),
+		c.GetHeader(provider.TimestampHeader),
+		c.GetHeader(provider.SignatureHeader),
+		body,
+	)
+	if err != nil {
+		callbackError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, Response{Message: "Delivery receipt accepted"})
+}
+
+// InboundMessage godoc
+// @Summary      Receive an inbound message
+// @Description  Accept a reply (MO message) forwarded by a gateway. The request must be signed with the provider's inbound secret.
+// @Tags         Callbacks
+// @Accept       json
+// @Produce      json
+// @Param        provider               path      string                 true  "Provider name"
+// @Param        X-Signature-Timestamp  header    string                 true  "Unix timestamp used in the signature"
+// @Param        X-Signature            header    string                 true  "Hex HMAC-SHA256 of <timestamp>.<body>"
+// @Param        message                body      InboundMessageRequest  true  "Inbound message"
+// @Success      202                    {object}  Response
+// @Failure      400                    {object}  Response
+// @Failure      401                    {object}  Response
+// @Failure      404                    {object}  Response
+// @Failure      500                    {object}  Response
+// @Router       /callbacks/inbound/{provider} [post]
+func (h *CallbackHandlers) InboundMessage(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	_, err = h.callbackService.HandleInboundMessage(
+		c.Request.Context(),
+		c.Param("provider"),
+		c.GetHeader(provider.TimestampHeader),
+		c.GetHeader(provider.SignatureHeader),
+		body,
+	)
+	if err != nil {
+		callbackError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, Response{Message: "Inbound message accepted"})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+// Gin context keys set when a request is authenticated
+const (
+	// APIKeyContextKey holds the caller's *models.APIKey
+	APIKeyContextKey = "api_key"
+	// TenantContextKey holds the caller's tenant ID
+	TenantContextKey = "tenant_id"
+)
+
+// tenantID returns the tenant of the authenticated caller
+func tenantID(c *gin.Context) uint {
+	if id, ok := c.Get(TenantContextKey); ok {
+		return id.(uint)
+	}
+	return models.DefaultTenantID
+}
+
+// apiKey returns the key the caller authenticated with, if any
+func apiKey(c *gin.Context) (*models.APIKey, bool) {
+	value, ok := c.Get(APIKeyContextKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*models.APIKey)
+	return key, ok
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type HealthHandlers struct {
+	healthService *service.HealthService
+}
+
+func NewHealthHandlers(healthService *service.HealthService) *HealthHandlers {
+	return &HealthHandlers{
+		healthService: healthService,
+	}
+}
+
+// Liveness reports that the process is up and serving requests. It does not
+// check dependencies, so an outage elsewhere never gets the container restarted.
+func (h *HealthHandlers) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": service.HealthOK})
+}
+
+// Readiness pings the database and Redis and lists the providers' circuit
+// breakers. It returns 503 when the database is unreachable and 200
+// otherwise; a Redis outage or a provider circuit that is not closed is
+// reported as "degraded" because the service keeps working without them.
+func (h *HealthHandlers) Readiness(c *gin.Context) {
+	report := h.healthService.Check(c.Request.Context())
+	if !report.Ready() {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/vkukul/messaging-system/internal/models"
 	"github.com/vkukul/messaging-system/internal/service"
 )
 
@@ -17,14 +20,97 @@ type Response struct {
 	Message string `json:"message"`
 }
 
+// Attachment represents a file sent with an email
+type Attachment struct {
+	Filename    string `json:"filename" example:"invoice.pdf"`
+	ContentType string `json:"content_type,omitempty" example:"application/pdf"`
+	Content     string `json:"content" example:"JVBERi0xLjQK"`
+}
+
+// Fallback represents the next channel to try when a message is not
+// delivered. Content defaults to that of the attempt it follows.
+type Fallback struct {
+	Channel  string `json:"channel" enums:"sms,email,push"`
+	To       string `json:"to"`
+	Content  string `json:"content,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	HTMLBody string `json:"html_body,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
 // Message represents a message in the system
 type Message struct {
-	ID        uint   `json:"id"`
-	To        string `json:"to"`
-	Content   string `json:"content"`
-	Sent      bool   `json:"sent"`
-	SentAt    string `json:"sent_at,omitempty"`
-	MessageID string `json:"message_id,omitempty"`
+	ID              uint              `json:"id"`
+	TenantID        uint              `json:"tenant_id"`
+	Channel         string            `json:"channel" enums:"sms,email,push"`
+	To              string            `json:"to" example:"+905551234567"`
+	CountryCode     int               `json:"country_code,omitempty" example:"90"`
+	Region          string            `json:"region,omitempty" example:"TR"`
+	Content         string            `json:"content"`
+	Subject         string            `json:"subject,omitempty"`
+	HTMLBody        string            `json:"html_body,omitempty"`
+	Attachments     []Attachment      `json:"attachments,omitempty"`
+	Title           string            `json:"title,omitempty"`
+	Data            map[string]string `json:"data,omitempty"`
+	Encoding        string            `json:"encoding" enums:"GSM-7,UCS-2"`
+	Segments        int               `json:"segments"`
+	Sent            bool              `json:"sent"`
+	SentAt          string            `json:"sent_at,omitempty"`
+	MessageID       string            `json:"message_id,omitempty"`
+	Provider        string            `json:"provider,omitempty" example:"primary"`
+	Status          string            `json:"status" enums:"pending,sending,sent,failed,dead_lettered,suppressed,needs_review"`
+	Attempts        int               `json:"attempts"`
+	LastError       string            `json:"last_error,omitempty"`
+	CallbackURL     string            `json:"callback_url,omitempty"`
+	TemplateID      uint              `json:"template_id,omitempty"`
+	OriginalID      uint              `json:"original_id,omitempty"`
+	FallbackID      uint              `json:"fallback_id,omitempty"`
+	Fallbacks       []Fallback        `json:"fallbacks,omitempty"`
+	FallbackTimeout int               `json:"fallback_timeout,omitempty"`
+	DeliveryStatus  string            `json:"delivery_status,omitempty" enums:"delivered,undeliverable,expired"`
+	DeliveredAt     string            `json:"delivered_at,omitempty"`
+	NextAttemptAt   string            `json:"next_attempt_at,omitempty"`
+	SendToken       string            `json:"send_token,omitempty"`
+	SendingAt       string            `json:"sending_at,omitempty"`
+}
+
+// DeliveryAttempt represents one request to a gateway to send a message
+type DeliveryAttempt struct {
+	ID         uint    `json:"id"`
+	MessageID  uint    `json:"message_id"`
+	Provider   string  `json:"provider" example:"primary"`
+	Status     string  `json:"status" enums:"sent,failed"`
+	Error      string  `json:"error,omitempty"`
+	ErrorClass string  `json:"error_class,omitempty" enums:"rate_limited,timeout,network,client_error,server_error,internal"`
+	Cost       float64 `json:"cost,omitempty" example:"0.012"`
+	DurationMS int64   `json:"duration_ms"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// CreateMessageRequest represents a request to queue a new message. The
+// channel defaults to sms, and the recipient is a phone number, an email
+// address or a device token accordingly. The content is either given
+// directly or rendered from a template; locale picks a template variant,
+// falling back to its language and then the default body. Emails need a
+// subject and content (the plain text body) or html_body; push
+// notifications need a title or content. Fallbacks are tried in order when
+// the message fails permanently or gets no delivery receipt within
+// fallback_timeout seconds (FALLBACK_TIMEOUT by default).
+type CreateMessageRequest struct {
+	Channel         string            `json:"channel,omitempty" enums:"sms,email,push"`
+	To              string            `json:"to" binding:"required" example:"+90 555 123 45 67"`
+	Content         string            `json:"content,omitempty"`
+	Subject         string            `json:"subject,omitempty"`
+	HTMLBody        string            `json:"html_body,omitempty"`
+	Attachments     []Attachment      `json:"attachments,omitempty"`
+	Title           string            `json:"title,omitempty"`
+	Data            map[string]string `json:"data,omitempty"`
+	TemplateID      *uint             `json:"template_id,omitempty"`
+	Locale          string            `json:"locale,omitempty" example:"pt-BR"`
+	Variables       map[string]string `json:"variables,omitempty"`
+	CallbackURL     string            `json:"callback_url,omitempty"`
+	Fallbacks       []Fallback        `json:"fallbacks,omitempty"`
+	FallbackTimeout int               `json:"fallback_timeout,omitempty" example:"600"`
 }
 
 func NewMessageHandlers(messageService *service.MessageService) *MessageHandlers {
@@ -35,12 +121,15 @@ func NewMessageHandlers(messageService *service.MessageService) *MessageHandlers
 
 // StartProcessing godoc
 // @Summary      Start message processing
-// @Description  Start the automatic message sending process that sends messages every 2 minutes
+// @Description  Start the automatic message sending process that sends messages every 2 minutes for every tenant. Needs an operator key of the default tenant.
 // @Tags         Messages
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Success      200  {object}  Response
 // @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
 // @Router       /messages/start [post]
 func (h *MessageHandlers) StartProcessing(c *gin.Context) {
 	if err := h.messageService.StartProcessing(); err != nil {
@@ -52,11 +141,14 @@ func (h *MessageHandlers) StartProcessing(c *gin.Context) {
 
 // StopProcessing godoc
 // @Summary      Stop message processing
-// @Description  Stop the automatic message sending process
+// @Description  Stop the automatic message sending process for every tenant. Needs an operator key of the default tenant.
 // @Tags         Messages
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Success      200  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
 // @Router       /messages/stop [post]
 func (h *MessageHandlers) StopProcessing(c *gin.Context) {
 	h.messageService.StopProcessing()
@@ -65,18 +157,130 @@ func (h *MessageHandlers) StopProcessing(c *gin.Context) {
 
 // GetSentMessages godoc
 // @Summary      Get sent messages
-// @Description  Get a list of all messages that have been sent
+// @Description  Get a list of all messages the caller's tenant has sent, optionally only those of one channel
 // @Tags         Messages
 // @Accept       json
 // @Produce      json
-// @Success      200  {array}   Message
-// @Failure      500  {object}  Response
+// @Security     ApiKeyAuth
+// @Param        channel  query     string  false  "Only return messages of this channel"  Enums(sms, email, push)
+// @Success      200      {array}   Message
+// @Failure      400      {object}  Response
+// @Failure      401      {object}  Response
+// @Failure      403      {object}  Response
+// @Failure      500      {object}  Response
 // @Router       /messages/sent [get]
 func (h *MessageHandlers) GetSentMessages(c *gin.Context) {
-	messages, err := h.messageService.GetSentMessages()
+	messages, err := h.messageService.GetSentMessages(tenantID(c), c.Query("channel"))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessage) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, messages)
 }
+
+// CreateMessage godoc
+// @Summary      Create a message
+// @Description  Queue a new SMS, email or push notification to be sent by the automatic sending process. Give either the content or a template_id with the variables it uses; templates are rendered now. SMS content may span up to MESSAGE_MAX_SEGMENTS segments. An optional fallback chain names the channels to try next when the message is not delivered. Retries sent with the same Idempotency-Key and body return the first response instead of creating another message.
+// @Tags         Messages
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        Idempotency-Key  header    string                false  "Key making retries of this request safe"
+// @Param        message          body      CreateMessageRequest  true   "Message to send"
+// @Success      201               {object}  Message
+// @Failure      400               {object}  Response
+// @Failure      401               {object}  Response
+// @Failure      403               {object}  Response
+// @Failure      404               {object}  Response
+// @Failure      409               {object}  Response
+// @Failure      429               {object}  Response
+// @Failure      500               {object}  Response
+// @Router       /messages [post]
+func (h *MessageHandlers) CreateMessage(c *gin.Context) {
+	var req CreateMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	msg := &models.Message{
+		TenantID:        tenantID(c),
+		Channel:         req.Channel,
+		To:              req.To,
+		Content:         req.Content,
+		Subject:         req.Subject,
+		HTMLBody:        req.HTMLBody,
+		Title:           req.Title,
+		Data:            req.Data,
+		CallbackURL:     req.CallbackURL,
+		FallbackTimeout: req.FallbackTimeout,
+	}
+	for _, attachment := range req.Attachments {
+		msg.Attachments = append(msg.Attachments, models.Attachment(attachment))
+	}
+	for _, fallback := range req.Fallbacks {
+		msg.Fallbacks = append(msg.Fallbacks, models.Fallback(fallback))
+	}
+
+	var err error
+	if req.TemplateID != nil {
+		if req.Content != "" {
+			c.JSON(http.StatusBadRequest, Response{Message: "give either content or template_id, not both"})
+			return
+		}
+		err = h.messageService.CreateMessageFromTemplate(c.Request.Context(), msg, *req.TemplateID, req.Locale, req.Variables)
+	} else {
+		err = h.messageService.CreateMessage(c.Request.Context(), msg)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMessage) || errors.Is(err, service.ErrInvalidTemplate) || errors.Is(err, service.ErrMissingTemplateVariables) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrTemplateNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, msg)
+}
+
+// GetDeliveryAttempts godoc
+// @Summary      Get delivery attempts
+// @Description  Get every request made to a gateway to send a message: the provider that handled it, its outcome and cost
+// @Tags         Messages
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Message ID"
+// @Success      200  {array}   DeliveryAttempt
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /messages/{id}/attempts [get]
+func (h *MessageHandlers) GetDeliveryAttempts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid message id"})
+		return
+	}
+
+	attempts, err := h.messageService.GetDeliveryAttempts(tenantID(c), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
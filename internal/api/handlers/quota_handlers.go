@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+type QuotaHandlers struct {
+	quotaService  *service.QuotaService
+	apiKeyService *service.APIKeyService
+}
+
+// QuotaUsage represents an API key's daily message creation quota
+type QuotaUsage struct {
+	APIKeyID  uint `json:"api_key_id"`
+	Unlimited bool `json:"unlimited"`
+	// Limit and Remaining are 0 when the quota is unlimited
+	Limit     int64 `json:"limit"`
+	Used      int64 `json:"used"`
+	Remaining int64 `json:"remaining"`
+	// ResetAt is when the quota starts over, at the start of the next UTC day
+	ResetAt string `json:"reset_at"`
+}
+
+func NewQuotaHandlers(quotaService *service.QuotaService, apiKeyService *service.APIKeyService) *QuotaHandlers {
+	return &QuotaHandlers{
+		quotaService:  quotaService,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// GetQuota godoc
+// @Summary      Get own quota usage
+// @Description  Get how many messages the calling API key has created today and how many it may still create
+// @Tags         Quotas
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  QuotaUsage
+// @Failure      401  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /quota [get]
+func (h *QuotaHandlers) GetQuota(c *gin.Context) {
+	key, ok := apiKey(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, Response{Message: service.ErrUnauthenticated.Error()})
+		return
+	}
+	h.writeUsage(c, key)
+}
+
+// GetAPIKeyQuota godoc
+// @Summary      Get an API key's quota usage
+// @Description  Get how many messages an API key of the caller's tenant has created today and how many it may still create
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {object}  QuotaUsage
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /keys/{id}/quota [get]
+func (h *QuotaHandlers) GetAPIKeyQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid API key id"})
+		return
+	}
+
+	key, err := h.apiKeyService.GetKey(tenantID(c), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	h.writeUsage(c, key)
+}
+
+func (h *QuotaHandlers) writeUsage(c *gin.Context, key *models.APIKey) {
+	usage, err := h.quotaService.MessageUsage(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toQuotaUsage(key, usage))
+}
+
+func toQuotaUsage(key *models.APIKey, usage *redis.QuotaResult) QuotaUsage {
+	resp := QuotaUsage{
+		APIKeyID:  key.ID,
+		Unlimited: usage.Limit == redis.Unlimited,
+		Used:      usage.Used,
+		ResetAt:   usage.ResetAt.Format(time.RFC3339),
+	}
+	if !resp.Unlimited {
+		resp.Limit = usage.Limit
+		resp.Remaining = usage.Remaining
+	}
+	return resp
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type SuppressionHandlers struct {
+	suppressionService *service.SuppressionService
+}
+
+// CreateSuppressionRequest represents a request to stop messaging a recipient
+type CreateSuppressionRequest struct {
+	Recipient string     `json:"recipient" binding:"required"`
+	Reason    string     `json:"reason" binding:"required" enums:"opt_out,complaint,bounce,manual"`
+	Note      string     `json:"note,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Suppression represents a recipient on a tenant's suppression list
+type Suppression struct {
+	ID        uint   `json:"id"`
+	TenantID  uint   `json:"tenant_id"`
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason" enums:"opt_out,complaint,bounce,manual"`
+	Note      string `json:"note,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func NewSuppressionHandlers(suppressionService *service.SuppressionService) *SuppressionHandlers {
+	return &SuppressionHandlers{
+		suppressionService: suppressionService,
+	}
+}
+
+// CreateSuppression godoc
+// @Summary      Suppress a recipient
+// @Description  Add a recipient to the caller's suppression list. The tenant's pending messages to suppressed recipients are not sent. An existing entry for the recipient is replaced.
+// @Tags         Suppressions
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        suppression  body      CreateSuppressionRequest  true  "Suppression to add"
+// @Success      201          {object}  Suppression
+// @Failure      400          {object}  Response
+// @Failure      401          {object}  Response
+// @Failure      403          {object}  Response
+// @Failure      500          {object}  Response
+// @Router       /suppressions [post]
+func (h *SuppressionHandlers) CreateSuppression(c *gin.Context) {
+	var req CreateSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	sup, err := h.suppressionService.Suppress(tenantID(c), req.Recipient, req.Reason, req.Note, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSuppression) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, sup)
+}
+
+// ListSuppressions godoc
+// @Summary      List suppressions
+// @Description  Get all entries of the caller's suppression list, including expired ones
+// @Tags         Suppressions
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   Suppression
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /suppressions [get]
+func (h *SuppressionHandlers) ListSuppressions(c *gin.Context) {
+	sups, err := h.suppressionService.List(tenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sups)
+}
+
+// GetSuppression godoc
+// @Summary      Get a suppression
+// @Description  Get the entry for a recipient on the caller's suppression list
+// @Tags         Suppressions
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        recipient  path      string  true  "Recipient"
+// @Success      200        {object}  Suppression
+// @Failure      401        {object}  Response
+// @Failure      403        {object}  Response
+// @Failure      404        {object}  Response
+// @Failure      500        {object}  Response
+// @Router       /suppressions/{recipient} [get]
+func (h *SuppressionHandlers) GetSuppression(c *gin.Context) {
+	sup, err := h.suppressionService.Get(tenantID(c), c.Param("recipient"))
+	if err != nil {
+		if errors.Is(err, service.ErrSuppressionNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sup)
+}
+
+// DeleteSuppression godoc
+// @Summary      Remove a suppression
+// @Description  Remove a recipient from the caller's suppression list
+// @Tags         Suppressions
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        recipient  path      string  true  "Recipient"
+// @Success      200        {object}  Response
+// @Failure      401        {object}  Response
+// @Failure      403        {object}  Response
+// @Failure      404        {object}  Response
+// @Failure      500        {object}  Response
+// @Router       /suppressions/{recipient} [delete]
+func (h *SuppressionHandlers) DeleteSuppression(c *gin.Context) {
+	if err := h.suppressionService.Remove(tenantID(c), c.Param("recipient")); err != nil {
+		if errors.Is(err, service.ErrSuppressionNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Message: "Suppression removed"})
+}
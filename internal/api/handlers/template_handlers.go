@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type TemplateHandlers struct {
+	templateService *service.TemplateService
+}
+
+// CreateTemplateRequest represents a request to save a template. Saving a
+// name that exists adds a new version. Variants maps locales to the bodies
+// used instead of Body for them.
+type CreateTemplateRequest struct {
+	Name     string            `json:"name" binding:"required"`
+	Body     string            `json:"body" binding:"required" example:"Hi {{name}}, your code is {{code}}"`
+	Variants map[string]string `json:"variants,omitempty"`
+}
+
+// Template represents one version of a message template
+type Template struct {
+	ID        uint              `json:"id"`
+	Name      string            `json:"name"`
+	Version   int               `json:"version"`
+	Body      string            `json:"body"`
+	Variants  map[string]string `json:"variants,omitempty"`
+	CreatedAt string            `json:"created_at"`
+}
+
+func NewTemplateHandlers(templateService *service.TemplateService) *TemplateHandlers {
+	return &TemplateHandlers{
+		templateService: templateService,
+	}
+}
+
+// CreateTemplate godoc
+// @Summary      Create a template
+// @Description  Save a message template with {{variable}} placeholders and optional locale variants. Saving a name that already exists adds the next version; earlier versions keep working.
+// @Tags         Templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        template  body      CreateTemplateRequest  true  "Template to save"
+// @Success      201       {object}  Template
+// @Failure      400       {object}  Response
+// @Failure      401       {object}  Response
+// @Failure      403       {object}  Response
+// @Failure      500       {object}  Response
+// @Router       /templates [post]
+func (h *TemplateHandlers) CreateTemplate(c *gin.Context) {
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	tmpl, err := h.templateService.CreateTemplate(tenantID(c), req.Name, req.Body, req.Variants)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTemplate) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, toTemplate(tmpl))
+}
+
+// ListTemplates godoc
+// @Summary      List templates
+// @Description  Get every version of the caller's tenant's templates
+// @Tags         Templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   Template
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /templates [get]
+func (h *TemplateHandlers) ListTemplates(c *gin.Context) {
+	templates, err := h.templateService.ListTemplates(tenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+
+	resp := make([]Template, 0, len(templates))
+	for i := range templates {
+		resp = append(resp, toTemplate(&templates[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTemplate godoc
+// @Summary      Get a template
+// @Description  Get one version of a template
+// @Tags         Templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Template ID"
+// @Success      200  {object}  Template
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /templates/{id} [get]
+func (h *TemplateHandlers) GetTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid template id"})
+		return
+	}
+
+	tmpl, err := h.templateService.GetTemplate(tenantID(c), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrTemplateNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toTemplate(tmpl))
+}
+
+func toTemplate(tmpl *models.Template) Template {
+	resp := Template{
+		ID:        tmpl.ID,
+		Name:      tmpl.Name,
+		Version:   tmpl.Version,
+		Body:      tmpl.Body,
+		CreatedAt: tmpl.CreatedAt.Format(time.RFC3339),
+	}
+	if len(tmpl.Variants) > 0 {
+		resp.Variants = make(map[string]string, len(tmpl.Variants))
+		for _, variant := range tmpl.Variants {
+			resp.Variants[variant.Locale] = variant.Body
+		}
+	}
+	return resp
+}
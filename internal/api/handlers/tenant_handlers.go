@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type TenantHandlers struct {
+	tenantService *service.TenantService
+	apiKeyService *service.APIKeyService
+}
+
+// CreateTenantRequest represents a request to register a tenant
+type CreateTenantRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Provider   string `json:"provider,omitempty"`
+	RateLimit  string `json:"rate_limit,omitempty" example:"sliding_window:10/1m"`
+	DailyQuota int    `json:"daily_quota,omitempty"`
+}
+
+// Tenant represents a tenant and its sending settings
+type Tenant struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Provider   string `json:"provider,omitempty"`
+	RateLimit  string `json:"rate_limit,omitempty"`
+	DailyQuota int    `json:"daily_quota"`
+	Active     bool   `json:"active"`
+}
+
+func NewTenantHandlers(tenantService *service.TenantService, apiKeyService *service.APIKeyService) *TenantHandlers {
+	return &TenantHandlers{
+		tenantService: tenantService,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateTenant godoc
+// @Summary      Create a tenant
+// @Description  Register a tenant with its own messages, API keys, webhooks, provider, rate limit and daily quota
+// @Tags         Tenants
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        tenant  body      CreateTenantRequest  true  "Tenant to register"
+// @Success      201     {object}  Tenant
+// @Failure      400     {object}  Response
+// @Failure      401     {object}  Response
+// @Failure      403     {object}  Response
+// @Failure      500     {object}  Response
+// @Router       /tenants [post]
+func (h *TenantHandlers) CreateTenant(c *gin.Context) {
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	tenant, err := h.tenantService.CreateTenant(req.Name, req.Provider, req.RateLimit, req.DailyQuota)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTenant) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, toTenant(tenant))
+}
+
+// ListTenants godoc
+// @Summary      List tenants
+// @Description  Get all tenants with their sending settings
+// @Tags         Tenants
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   Tenant
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /tenants [get]
+func (h *TenantHandlers) ListTenants(c *gin.Context) {
+	tenants, err := h.tenantService.ListTenants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+
+	resp := make([]Tenant, 0, len(tenants))
+	for i := range tenants {
+		resp = append(resp, toTenant(&tenants[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateTenantAPIKey godoc
+// @Summary      Create an API key for a tenant
+// @Description  Issue the first API key of a tenant, or any key on its behalf, with scopes the caller's key holds. processor:admin and tenants:admin are only available to the default tenant. The key is only shown in this response.
+// @Tags         Tenants
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int                  true  "Tenant ID"
+// @Param        key  body      CreateAPIKeyRequest  true  "Key to issue"
+// @Success      201  {object}  APIKey
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /tenants/{id}/keys [post]
+func (h *TenantHandlers) CreateTenantAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid tenant id"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	tenant, err := h.tenantService.GetTenant(uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrTenantNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+
+	createAPIKey(c, h.apiKeyService, tenant.ID, &req)
+}
+
+func toTenant(tenant *models.Tenant) Tenant {
+	return Tenant{
+		ID:         tenant.ID,
+		Name:       tenant.Name,
+		Provider:   tenant.Provider,
+		RateLimit:  tenant.RateLimit,
+		DailyQuota: tenant.DailyQuota,
+		Active:     tenant.Active,
+	}
+}
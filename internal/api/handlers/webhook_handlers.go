@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+type WebhookHandlers struct {
+	webhookService *service.WebhookService
+}
+
+// CreateWebhookRequest represents a request to register a status-change callback
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events,omitempty" enums:"message.sent,message.failed,message.dead_lettered,message.delivered,message.undeliverable,message.expired,message.inbound,message.suppressed,message.needs_review,message.fallback"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// Webhook represents a registered callback. The secret is only returned on creation.
+type Webhook struct {
+	ID     uint   `json:"id"`
+	URL    string `json:"url"`
+	Events string `json:"events"`
+	Active bool   `json:"active"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookDelivery represents one entry of a webhook's delivery log
+type WebhookDelivery struct {
+	ID            uint   `json:"id"`
+	MessageID     uint   `json:"message_id"`
+	EventType     string `json:"event_type"`
+	Status        string `json:"status" enums:"pending,delivered,failed"`
+	Attempts      int    `json:"attempts"`
+	ResponseCode  int    `json:"response_code,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	DeliveredAt   string `json:"delivered_at,omitempty"`
+}
+
+func NewWebhookHandlers(webhookService *service.WebhookService) *WebhookHandlers {
+	return &WebhookHandlers{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Register a callback URL that receives signed POSTs when messages are sent, fail or are dead-lettered
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        webhook  body      CreateWebhookRequest  true  "Webhook to register"
+// @Success      201      {object}  Webhook
+// @Failure      400      {object}  Response
+// @Failure      401      {object}  Response
+// @Failure      403      {object}  Response
+// @Failure      500      {object}  Response
+// @Router       /webhooks [post]
+func (h *WebhookHandlers) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(tenantID(c), req.URL, req.Events, req.Secret)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			c.JSON(http.StatusBadRequest, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, Webhook{
+		ID:     sub.ID,
+		URL:    sub.URL,
+		Events: sub.Events,
+		Active: sub.Active,
+		Secret: sub.Secret,
+	})
+}
+
+// ListWebhooks godoc
+// @Summary      List webhooks
+// @Description  Get the webhook subscriptions of the caller's tenant
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   Webhook
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /webhooks [get]
+func (h *WebhookHandlers) ListWebhooks(c *gin.Context) {
+	subs, err := h.webhookService.ListSubscriptions(tenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Remove a webhook subscription
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  Response
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      404  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /webhooks/{id} [delete]
+func (h *WebhookHandlers) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid webhook id"})
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(tenantID(c), uint(id)); err != nil {
+		if errors.Is(err, service.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, Response{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, Response{Message: "Webhook deleted"})
+}
+
+// ListWebhookDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Get the most recent delivery attempts for a webhook subscription
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {array}   WebhookDelivery
+// @Failure      400  {object}  Response
+// @Failure      401  {object}  Response
+// @Failure      403  {object}  Response
+// @Failure      500  {object}  Response
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandlers) ListWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Message: "invalid webhook id"})
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(tenantID(c), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
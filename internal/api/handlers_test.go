@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
 	"github.com/vkukul/messaging-system/pkg/database"
 	"github.com/vkukul/messaging-system/pkg/redis"
 )
@@ -19,6 +26,22 @@ func setupTestRouter() *gin.Engine {
 	return router
 }
 
+// testAPIKey issues a key with the given scopes, or every scope if none are
+// given, and revokes it when the test ends
+func testAPIKey(t *testing.T, scopes ...string) string {
+	if len(scopes) == 0 {
+		scopes = []string{models.ScopeMessagesWrite, models.ScopeMessagesRead, models.ScopeProcessorAdmin, models.ScopeKeysAdmin}
+	}
+	key, raw, err := service.NewAPIKeyService().CreateKey(models.DefaultTenantID, "test", scopes, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Unscoped().Delete(key)
+	})
+	return raw
+}
+
 func TestStartProcessingHandler(t *testing.T) {
 	if err := redis.InitRedis(); err != nil {
 		t.Fatalf("Failed to initialize Redis: %v", err)
@@ -28,6 +51,7 @@ func TestStartProcessingHandler(t *testing.T) {
 	}
 
 	router := setupTestRouter()
+	apiKey := testAPIKey(t)
 
 	tests := []struct {
 		name         string
@@ -57,6 +81,7 @@ func TestStartProcessingHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(APIKeyHeader, apiKey)
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.wantStatus, w.Code)
@@ -72,7 +97,12 @@ func TestStartProcessingHandler(t *testing.T) {
 }
 
 func TestStopProcessingHandler(t *testing.T) {
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
 	router := setupTestRouter()
+	apiKey := testAPIKey(t)
 
 	tests := []struct {
 		name         string
@@ -102,6 +132,7 @@ func TestStopProcessingHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(APIKeyHeader, apiKey)
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.wantStatus, w.Code)
@@ -125,6 +156,7 @@ func TestGetSentMessagesHandler(t *testing.T) {
 	}
 
 	router := setupTestRouter()
+	apiKey := testAPIKey(t)
 
 	tests := []struct {
 		name       string
@@ -138,6 +170,18 @@ func TestGetSentMessagesHandler(t *testing.T) {
 			path:       "/api/v1/messages/sent",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "Filter by channel",
+			method:     "GET",
+			path:       "/api/v1/messages/sent?channel=email",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Unknown channel",
+			method:     "GET",
+			path:       "/api/v1/messages/sent?channel=fax",
+			wantStatus: http.StatusBadRequest,
+		},
 		{
 			name:       "Invalid method",
 			method:     "POST",
@@ -150,6 +194,7 @@ func TestGetSentMessagesHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(APIKeyHeader, apiKey)
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.wantStatus, w.Code)
@@ -162,3 +207,379 @@ func TestGetSentMessagesHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateMessageHandler(t *testing.T) {
+	if err := redis.InitRedis(); err != nil {
+		t.Fatalf("Failed to initialize Redis: %v", err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	router := setupTestRouter()
+	apiKey := testAPIKey(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Successfully create message",
+			body:       `{"to":"+905551234567","content":"Test message"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Invalid recipient",
+			body:       `{"to":"12345","content":"Test message"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Missing content",
+			body:       `{"to":"+905551234567"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown channel",
+			body:       `{"channel":"fax","to":"+905551234567","content":"Test message"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Channel without provider",
+			body:       `{"channel":"email","to":"jane@example.com","subject":"Hi","content":"Test message"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "With fallback",
+			body:       `{"to":"+905551234567","content":"Test message","fallbacks":[{"channel":"sms","to":"+905551234568"}]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Invalid fallback",
+			body:       `{"to":"+905551234567","content":"Test message","fallbacks":[{"channel":"email","to":"jane@"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Email fields on SMS",
+			body:       `{"to":"+905551234567","subject":"Hi","content":"Test message"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Long content spans several segments",
+			body:       `{"to":"+905551234567","content":"` + strings.Repeat("a", 161) + `"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Content too long",
+			body:       `{"to":"+905551234567","content":"` + strings.Repeat("a", 3*153+1) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/v1/messages", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(APIKeyHeader, apiKey)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var msg models.Message
+				err := json.Unmarshal(w.Body.Bytes(), &msg)
+				assert.NoError(t, err)
+				assert.NotZero(t, msg.ID)
+				assert.False(t, msg.Sent)
+
+				var events int64
+				database.DB.Model(&models.OutboxEvent{}).
+					Where("aggregate_id = ? AND event_type = ?", msg.ID, models.EventMessageCreated).
+					Count(&events)
+				assert.Equal(t, int64(1), events)
+
+				database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+				database.DB.Unscoped().Delete(&msg)
+			}
+		})
+	}
+}
+
+func TestCreateMessageIdempotency(t *testing.T) {
+	if err := redis.InitRedis(); err != nil {
+		t.Fatalf("Failed to initialize Redis: %v", err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	router := setupTestRouter()
+	apiKey := testAPIKey(t)
+	idempotencyKey := uuid.New().String()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(APIKeyHeader, apiKey)
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"to":"+905551234567","content":"Idempotent message"}`
+	first := send(body)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	var msg models.Message
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &msg))
+
+	// A retry gets the same response without creating another message
+	retry := send(body)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	var count int64
+	database.DB.Model(&models.Message{}).Where("content = ?", "Idempotent message").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// The same key with a different payload is a conflict
+	conflict := send(`{"to":"+905551234567","content":"Another message"}`)
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+
+	database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+	database.DB.Unscoped().Delete(&msg)
+	redis.DeleteIdempotencyRecord(context.Background(), models.DefaultTenantID, idempotencyKey)
+}
+
+func TestCreateMessageFromTemplate(t *testing.T) {
+	if err := redis.InitRedis(); err != nil {
+		t.Fatalf("Failed to initialize Redis: %v", err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	router := setupTestRouter()
+	apiKey := testAPIKey(t)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(APIKeyHeader, apiKey)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/templates", `{"name":"otp-`+uuid.New().String()+`","body":"Your code is {{code}}","variants":{"tr":"Kodunuz {{code}}"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var tmpl handlers.Template
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tmpl))
+	assert.Equal(t, 1, tmpl.Version)
+	t.Cleanup(func() {
+		database.DB.Where("template_id = ?", tmpl.ID).Delete(&models.TemplateVariant{})
+		database.DB.Delete(&models.Template{}, tmpl.ID)
+	})
+	templateID := strconv.FormatUint(uint64(tmpl.ID), 10)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantContent string
+	}{
+		{
+			name:        "Default body",
+			body:        `{"to":"+905551234567","template_id":` + templateID + `,"variables":{"code":"1234"}}`,
+			wantStatus:  http.StatusCreated,
+			wantContent: "Your code is 1234",
+		},
+		{
+			name:        "Locale variant",
+			body:        `{"to":"+905551234567","template_id":` + templateID + `,"locale":"tr-TR","variables":{"code":"1234"}}`,
+			wantStatus:  http.StatusCreated,
+			wantContent: "Kodunuz 1234",
+		},
+		{
+			name:       "Missing variable",
+			body:       `{"to":"+905551234567","template_id":` + templateID + `}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Rendered content too long",
+			body:       `{"to":"+905551234567","template_id":` + templateID + `,"variables":{"code":"` + strings.Repeat("1", 3*153) + `"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Content and template",
+			body:       `{"to":"+905551234567","content":"Hi","template_id":` + templateID + `,"variables":{"code":"1234"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown template",
+			body:       `{"to":"+905551234567","template_id":999999999,"variables":{"code":"1234"}}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post("/api/v1/messages", tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var msg models.Message
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &msg))
+				assert.Equal(t, tt.wantContent, msg.Content)
+				if assert.NotNil(t, msg.TemplateID) {
+					assert.Equal(t, tmpl.ID, *msg.TemplateID)
+				}
+
+				database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+				database.DB.Unscoped().Delete(&msg)
+			}
+		})
+	}
+}
+
+func TestGetDeliveryAttemptsHandler(t *testing.T) {
+	if err := redis.InitRedis(); err != nil {
+		t.Fatalf("Failed to initialize Redis: %v", err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	router := setupTestRouter()
+	apiKey := testAPIKey(t)
+
+	msg := &models.Message{TenantID: models.DefaultTenantID, To: "+905551234567", Content: "Attempted message", Status: models.StatusSent}
+	if err := database.DB.Create(msg).Error; err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	attempt := &models.DeliveryAttempt{MessageID: msg.ID, TenantID: msg.TenantID, Provider: "primary", Status: models.AttemptSent}
+	if err := database.DB.Create(attempt).Error; err != nil {
+		t.Fatalf("Failed to create delivery attempt: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Delete(attempt)
+		database.DB.Unscoped().Delete(msg)
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name:         "Successfully get attempts",
+			path:         "/api/v1/messages/" + strconv.Itoa(int(msg.ID)) + "/attempts",
+			wantStatus:   http.StatusOK,
+			wantAttempts: 1,
+		},
+		{
+			name:       "Unknown message",
+			path:       "/api/v1/messages/999999999/attempts",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid message id",
+			path:       "/api/v1/messages/abc/attempts",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set(APIKeyHeader, apiKey)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				var attempts []models.DeliveryAttempt
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &attempts))
+				assert.Len(t, attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyScopeEscalation(t *testing.T) {
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	router := setupTestRouter()
+	keysAdmin := testAPIKey(t, models.ScopeKeysAdmin)
+	operator := testAPIKey(t, models.ScopeKeysAdmin, models.ScopeTenantsAdmin)
+
+	tenant, err := service.NewTenantService().CreateTenant("escalation-"+uuid.NewString(), "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to create tenant: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Where("tenant_id = ?", tenant.ID).Delete(&models.APIKey{})
+		database.DB.Delete(tenant)
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Scope the caller holds",
+			key:        keysAdmin,
+			path:       "/api/v1/keys",
+			body:       `{"name":"ci","scopes":["keys:admin"]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Tenant admin from a keys admin",
+			key:        keysAdmin,
+			path:       "/api/v1/keys",
+			body:       `{"name":"ci","scopes":["tenants:admin"]}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Processor admin from a keys admin",
+			key:        keysAdmin,
+			path:       "/api/v1/keys",
+			body:       `{"name":"ci","scopes":["keys:admin","processor:admin"]}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Tenant admin for another tenant",
+			key:        operator,
+			path:       "/api/v1/tenants/" + strconv.Itoa(int(tenant.ID)) + "/keys",
+			body:       `{"name":"ci","scopes":["tenants:admin"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(APIKeyHeader, tt.key)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if w.Code == http.StatusCreated {
+				var key handlers.APIKey
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+				database.DB.Unscoped().Delete(&models.APIKey{}, key.ID)
+			}
+		})
+	}
+}
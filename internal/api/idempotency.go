@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/service"
+)
+
+const (
+	// IdempotencyKeyHeader makes a request safe to retry: repeats with the
+	// same key and body get the first response instead of being processed
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// responseRecorder keeps a copy of the response body while writing it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent replays the stored response when a request carrying an
+// Idempotency-Key is retried, and rejects the key with 409 when it is reused
+// for a different request or the first one is still running. Only
+// successful responses are stored; after a failure the retry is processed
+// again. It must run after authenticate.
+func idempotent(idempotency *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		tenantID := c.MustGet(handlers.TenantContextKey).(uint)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.Response{Message: "error reading request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := hashRequest(c.Request.Method, c.FullPath(), body)
+
+		record, err := idempotency.Begin(ctx, tenantID, key, requestHash)
+		switch {
+		case errors.Is(err, service.ErrInvalidIdempotencyKey):
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+			return
+		case errors.Is(err, service.ErrIdempotencyKeyReused), errors.Is(err, service.ErrIdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, handlers.Response{Message: err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, handlers.Response{Message: err.Error()})
+			return
+		case record != nil:
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusOK && status < http.StatusMultipleChoices {
+			err := idempotency.Complete(ctx, tenantID, key, requestHash, status, recorder.body.Bytes())
+			if err == nil {
+				return
+			}
+			slog.ErrorContext(ctx, "Failed to record idempotent response", "error", err)
+		}
+		// Without a stored response a retry must be processed again rather
+		// than wait for the claim to expire
+		if err := idempotency.Release(ctx, tenantID, key); err != nil {
+			slog.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+		}
+	}
+}
+
+// hashRequest identifies a request by its method, route and body
+func hashRequest(method, route string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + route + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
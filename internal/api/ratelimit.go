@@ -0,0 +1,100 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// Headers describing the request rate limit and the daily message quota.
+// Reset headers are seconds until the limit is fully replenished.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+	QuotaLimitHeader         = "X-Quota-Limit"
+	QuotaRemainingHeader     = "X-Quota-Remaining"
+	QuotaResetHeader         = "X-Quota-Reset"
+)
+
+// limitRequests rejects clients exceeding their request rate with 429. It
+// must run after authenticate. Requests go through if Redis is unavailable.
+func limitRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.MustGet(handlers.APIKeyContextKey).(*models.APIKey)
+		result, err := redis.Allow(c.Request.Context(), redis.ClassAPI, strconv.FormatUint(uint64(key.ID), 10))
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "API rate limit check failed", "api_key_id", key.ID, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header(RateLimitLimitHeader, strconv.FormatInt(result.Limit, 10))
+		c.Header(RateLimitRemainingHeader, strconv.FormatInt(result.Remaining, 10))
+		c.Header(RateLimitResetHeader, headerSeconds(result.ResetAfter))
+		if !result.Allowed {
+			rejectLimited(c, metrics.LimitRequests, result.RetryAfter, "request rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitMessageQuota counts message creation against the client's daily
+// quota and rejects it with 429 once the quota is used up. Requests that do
+// not create a message get their unit back. It must run after authenticate.
+func limitMessageQuota(quotas *service.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		key := c.MustGet(handlers.APIKeyContextKey).(*models.APIKey)
+		result, err := quotas.ConsumeMessage(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "Message quota check failed", "api_key_id", key.ID, "error", err)
+			c.Next()
+			return
+		}
+
+		if result.Limit != redis.Unlimited {
+			c.Header(QuotaLimitHeader, strconv.FormatInt(result.Limit, 10))
+			c.Header(QuotaRemainingHeader, strconv.FormatInt(result.Remaining, 10))
+			c.Header(QuotaResetHeader, headerSeconds(time.Until(result.ResetAt)))
+		}
+		if !result.Allowed {
+			rejectLimited(c, metrics.LimitDailyMessages, time.Until(result.ResetAt), "daily message quota exceeded")
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusMultipleChoices {
+			if err := quotas.RefundMessage(ctx, key); err != nil {
+				slog.WarnContext(ctx, "Failed to refund message quota", "api_key_id", key.ID, "error", err)
+			}
+		}
+	}
+}
+
+func rejectLimited(c *gin.Context, limit string, retryAfter time.Duration, message string) {
+	metrics.APIRateLimited.WithLabelValues(limit).Inc()
+	c.Header("Retry-After", headerSeconds(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.Response{Message: message})
+}
+
+// headerSeconds rounds a duration up to whole seconds, at least one for
+// anything still pending
+func headerSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/service"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// setupLocalRedis points the Redis client at an in-process stand-in
+func setupLocalRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	previous := redis.Client
+	redis.Client = goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		redis.Client.Close()
+		redis.Client = previous
+	})
+}
+
+// limitedRouter serves /ping for a fixed API key behind the given limiters.
+// The handler answers with the status in the "status" query parameter.
+func limitedRouter(key *models.APIKey, limiters ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(handlers.APIKeyContextKey, key)
+		c.Next()
+	})
+	router.Use(limiters...)
+	router.POST("/ping", func(c *gin.Context) {
+		status, err := strconv.Atoi(c.DefaultQuery("status", "201"))
+		if err != nil {
+			status = http.StatusCreated
+		}
+		c.Status(status)
+	})
+	return router
+}
+
+func TestLimitRequests(t *testing.T) {
+	setupLocalRedis(t)
+	t.Setenv("RATE_LIMIT_API", "sliding_window:2/1m")
+	router := limitedRouter(&models.APIKey{ID: 1}, limitRequests())
+
+	for i := 2; i > 0; i-- {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "2", w.Header().Get(RateLimitLimitHeader))
+		assert.Equal(t, strconv.Itoa(i-1), w.Header().Get(RateLimitRemainingHeader))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(RateLimitRemainingHeader))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Greater(t, retryAfter, 0)
+	assert.LessOrEqual(t, retryAfter, 60)
+
+	// Another client has its own allowance
+	other := limitedRouter(&models.APIKey{ID: 2}, limitRequests())
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestLimitMessageQuota(t *testing.T) {
+	setupLocalRedis(t)
+	quotas := service.NewQuotaService()
+	key := &models.APIKey{ID: 1, DailyMessageQuota: 2}
+	router := limitedRouter(key, limitMessageQuota(quotas))
+
+	// Rejected requests do not use up the quota
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping?status=400", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for i := 1; i >= 0; i-- {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "2", w.Header().Get(QuotaLimitHeader))
+		assert.Equal(t, strconv.Itoa(i), w.Header().Get(QuotaRemainingHeader))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	usage, err := quotas.MessageUsage(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), usage.Used)
+	assert.Equal(t, int64(0), usage.Remaining)
+}
+
+func TestLimitMessageQuotaUnlimited(t *testing.T) {
+	setupLocalRedis(t)
+	t.Setenv("API_DAILY_MESSAGE_QUOTA", "0")
+	router := limitedRouter(&models.APIKey{ID: 1}, limitMessageQuota(service.NewQuotaService()))
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ping", nil))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get(QuotaLimitHeader))
+	}
+}
@@ -1,32 +1,132 @@
 package api
 
 import (
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	handlers "github.com/vkukul/messaging-system/internal/api/handlers"
+	"github.com/vkukul/messaging-system/internal/models"
 	service "github.com/vkukul/messaging-system/internal/service"
 )
 
 func SetupRoutes(r *gin.Engine) {
-	// Create message service
+	// Create services
 	messageService := service.NewMessageService()
+	webhookService := service.NewWebhookService()
+	callbackService := service.NewCallbackService()
+	suppressionService := service.NewSuppressionService()
+	healthService := service.NewHealthService(messageService)
+	apiKeyService := service.NewAPIKeyService()
+	tenantService := service.NewTenantService()
+	quotaService := service.NewQuotaService()
+	idempotencyService := service.NewIdempotencyService()
+	templateService := service.NewTemplateService()
+
+	r.Use(requestIDMiddleware(), tracingMiddleware(), requestLogger())
 
 	// Create handlers
 	messageHandlers := handlers.NewMessageHandlers(messageService)
+	webhookHandlers := handlers.NewWebhookHandlers(webhookService)
+	callbackHandlers := handlers.NewCallbackHandlers(callbackService)
+	suppressionHandlers := handlers.NewSuppressionHandlers(suppressionService)
+	healthHandlers := handlers.NewHealthHandlers(healthService)
+	apiKeyHandlers := handlers.NewAPIKeyHandlers(apiKeyService)
+	tenantHandlers := handlers.NewTenantHandlers(tenantService, apiKeyService)
+	quotaHandlers := handlers.NewQuotaHandlers(quotaService, apiKeyService)
+	templateHandlers := handlers.NewTemplateHandlers(templateService)
+
+	// Callbacks are authenticated by provider signatures, everything else
+	// under /api/v1 by API key and rate limited per key
+	auth := authenticate(apiKeyService)
+	limit := limitRequests()
 
 	// API v1 group
 	v1 := r.Group("/api/v1")
 	{
-		messages := v1.Group("/messages")
+		messages := v1.Group("/messages", auth, limit)
+		{
+			messages.POST("", requireScope(models.ScopeMessagesWrite), idempotent(idempotencyService), limitMessageQuota(quotaService), messageHandlers.CreateMessage)
+			messages.POST("/start", requireScope(models.ScopeProcessorAdmin), messageHandlers.StartProcessing)
+			messages.POST("/stop", requireScope(models.ScopeProcessorAdmin), messageHandlers.StopProcessing)
+			messages.GET("/sent", requireScope(models.ScopeMessagesRead), messageHandlers.GetSentMessages)
+			messages.GET("/:id/attempts", requireScope(models.ScopeMessagesRead), messageHandlers.GetDeliveryAttempts)
+		}
+
+		webhooks := v1.Group("/webhooks", auth, limit)
+		{
+			webhooks.POST("", requireScope(models.ScopeMessagesWrite), webhookHandlers.CreateWebhook)
+			webhooks.GET("", requireScope(models.ScopeMessagesRead), webhookHandlers.ListWebhooks)
+			webhooks.DELETE("/:id", requireScope(models.ScopeMessagesWrite), webhookHandlers.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", requireScope(models.ScopeMessagesRead), webhookHandlers.ListWebhookDeliveries)
+		}
+
+		templates := v1.Group("/templates", auth, limit)
+		{
+			templates.POST("", requireScope(models.ScopeMessagesWrite), templateHandlers.CreateTemplate)
+			templates.GET("", requireScope(models.ScopeMessagesRead), templateHandlers.ListTemplates)
+			templates.GET("/:id", requireScope(models.ScopeMessagesRead), templateHandlers.GetTemplate)
+		}
+
+		suppressions := v1.Group("/suppressions", auth, limit)
+		{
+			suppressions.POST("", requireScope(models.ScopeMessagesWrite), suppressionHandlers.CreateSuppression)
+			suppressions.GET("", requireScope(models.ScopeMessagesRead), suppressionHandlers.ListSuppressions)
+			suppressions.GET("/:recipient", requireScope(models.ScopeMessagesRead), suppressionHandlers.GetSuppression)
+			suppressions.DELETE("/:recipient", requireScope(models.ScopeMessagesWrite), suppressionHandlers.DeleteSuppression)
+		}
+
+		keys := v1.Group("/keys", auth, limit, requireScope(models.ScopeKeysAdmin))
 		{
-			messages.POST("/start", messageHandlers.StartProcessing)
-			messages.POST("/stop", messageHandlers.StopProcessing)
-			messages.GET("/sent", messageHandlers.GetSentMessages)
+			keys.POST("", apiKeyHandlers.CreateAPIKey)
+			keys.GET("", apiKeyHandlers.ListAPIKeys)
+			keys.DELETE("/:id", apiKeyHandlers.RevokeAPIKey)
+			keys.GET("/:id/quota", quotaHandlers.GetAPIKeyQuota)
+		}
+
+		// Any key may read its own quota
+		v1.GET("/quota", auth, limit, quotaHandlers.GetQuota)
+
+		tenants := v1.Group("/tenants", auth, limit, requireScope(models.ScopeTenantsAdmin))
+		{
+			tenants.POST("", tenantHandlers.CreateTenant)
+			tenants.GET("", tenantHandlers.ListTenants)
+			tenants.POST("/:id/keys", tenantHandlers.CreateTenantAPIKey)
+		}
+
+		callbacks := v1.Group("/callbacks")
+		{
+			callbacks.POST("/dlr/:provider", callbackHandlers.DeliveryReceipt)
+			callbacks.POST("/inbound/:provider", callbackHandlers.InboundMessage)
 		}
 	}
 
+	// Endpoints outside /api/v1 need any valid key unless listed in PUBLIC_ENDPOINTS
+	public := publicEndpoints()
+	guard := func(name string) []gin.HandlerFunc {
+		if public[name] {
+			return nil
+		}
+		return []gin.HandlerFunc{auth, limit}
+	}
+
+	// Liveness and readiness probes
+	r.GET("/healthz", append(guard("health"), healthHandlers.Liveness)...)
+	r.GET("/readyz", append(guard("health"), healthHandlers.Readiness)...)
+
+	// Prometheus metrics
+	r.GET("/metrics", append(guard("metrics"), gin.WrapH(promhttp.Handler()))...)
+
 	// Swagger documentation
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/swagger/*any", append(guard("swagger"), ginSwagger.WrapHandler(swaggerFiles.Handler))...)
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
 }
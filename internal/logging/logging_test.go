@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMaskRecipient(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient string
+		want      string
+	}{
+		{name: "International number", recipient: "+905551234567", want: "+********4567"},
+		{name: "Local number", recipient: "5551234567", want: "******4567"},
+		{name: "Short code", recipient: "1234", want: "****"},
+		{name: "Short international", recipient: "+123", want: "+***"},
+		{name: "Empty", recipient: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MaskRecipient(tt.recipient))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		wantErr bool
+	}{
+		{name: "JSON", format: FormatJSON, level: "info", wantErr: false},
+		{name: "Text", format: FormatText, level: "DEBUG", wantErr: false},
+		{name: "Unknown format", format: "xml", level: "info", wantErr: true},
+		{name: "Unknown level", format: FormatJSON, level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&bytes.Buffer{}, tt.format, tt.level)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCorrelationFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "info")
+	assert.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithRequestID(ctx, "req-1")
+
+	logger.With(Recipient("+905551234567")).InfoContext(ctx, "Message sent", "message_id", 42)
+	logger.DebugContext(ctx, "Not logged at info level")
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Message sent", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+	assert.Equal(t, "+********4567", record["recipient"])
+	assert.Equal(t, float64(42), record["message_id"])
+}
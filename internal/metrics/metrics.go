@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "messaging"
+
+// API limits that can reject a request
+const (
+	LimitRequests      = "requests"
+	LimitDailyMessages = "daily_messages"
+)
+
+// Outcomes of reconciling a send that was never recorded
+const (
+	ReconcileSent        = "sent"
+	ReconcileNotReceived = "not_received"
+	ReconcileNeedsReview = "needs_review"
+)
+
+// Why a message fell back to its next channel
+const (
+	FallbackFailed  = "failed"
+	FallbackTimeout = "timeout"
+)
+
+// Webhook destinations
+const (
+	DestinationProvider = "provider"
+	DestinationClient   = "client"
+)
+
+var (
+	// MessagesSent counts messages accepted by a provider
+	MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Messages accepted by the provider.",
+	}, []string{"provider"})
+
+	// SegmentsSent counts the SMS segments of messages accepted by a
+	// provider, which is what providers bill for
+	SegmentsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_sent_total",
+		Help:      "SMS segments of messages accepted by the provider.",
+	}, []string{"provider"})
+
+	// MessagesFailed counts processing rounds that ended in failure
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Processing rounds that failed to send a message.",
+	}, []string{"provider", "error_class"})
+
+	// MessagesRetried counts send attempts that are retried within a round
+	MessagesRetried = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_retried_total",
+		Help:      "Send attempts that failed and were retried.",
+	}, []string{"provider", "error_class"})
+
+	// MessagesReconciled counts unrecorded sends resolved by the reconciler
+	MessagesReconciled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_reconciled_total",
+		Help:      "Messages stuck in sending that were resolved by the reconciler.",
+	}, []string{"outcome"})
+
+	// ProviderFailovers counts sends moved to another provider after a
+	// retryable error
+	ProviderFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_failovers_total",
+		Help:      "Send attempts moved to another provider after a retryable error.",
+	}, []string{"from", "to"})
+
+	// ProviderCircuitState is the state of a provider's circuit breaker
+	ProviderCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "provider_circuit_state",
+		Help:      "State of the provider's circuit breaker: 0 closed, 1 half-open, 2 open.",
+	}, []string{"provider"})
+
+	// ProviderCircuitOpened counts how often a provider's circuit opened
+	ProviderCircuitOpened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_circuit_opened_total",
+		Help:      "Times the provider's circuit breaker opened.",
+	}, []string{"provider"})
+
+	// FallbacksStarted counts messages that fell back to their next channel
+	FallbacksStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fallbacks_started_total",
+		Help:      "Messages that were not delivered and fell back to their next channel.",
+	}, []string{"from", "to", "reason"})
+
+	// WebhookLatency observes outbound webhook requests, both to providers
+	// and to client callback URLs
+	WebhookLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "webhook_request_duration_seconds",
+		Help:      "Duration of outbound webhook requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"destination", "status"})
+
+	// QueueLatency observes the time from a message being created to it
+	// being sent
+	QueueLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "queue_latency_seconds",
+		Help:      "Time from message creation to the provider accepting it.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	})
+
+	// Backlog is the number of messages waiting to be sent
+	Backlog = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backlog_messages",
+		Help:      "Messages pending or failed and waiting to be sent.",
+	})
+
+	// WorkersInFlight is the number of messages being processed
+	WorkersInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_in_flight",
+		Help:      "Messages currently being processed by workers.",
+	})
+
+	// ProcessorRunning is 1 while automatic sending is started
+	ProcessorRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "processor_running",
+		Help:      "Whether the automatic message processor is running.",
+	})
+
+	// RedisErrors counts failed Redis commands
+	RedisErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Redis commands that returned an error.",
+	}, []string{"command"})
+
+	// APIRateLimited counts API requests rejected with 429
+	APIRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limited_total",
+		Help:      "API requests rejected by a per-client rate limit or quota.",
+	}, []string{"limit"})
+
+	// DatabaseErrors counts failed database operations
+	DatabaseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "database_errors_total",
+		Help:      "Database operations that returned an error.",
+	}, []string{"operation"})
+)
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeMessagesWrite  = "messages:write"
+	ScopeMessagesRead   = "messages:read"
+	ScopeProcessorAdmin = "processor:admin"
+	ScopeKeysAdmin      = "keys:admin"
+	ScopeTenantsAdmin   = "tenants:admin"
+)
+
+// APIKey authenticates API clients. Only a SHA-256 hash of the key is stored;
+// Prefix keeps the first characters so keys can be told apart in listings.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	TenantID   uint       `json:"tenant_id" gorm:"not null;default:1;index"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	KeyHash    string     `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	UsageCount int64      `json:"usage_count" gorm:"default:0"`
+	// DailyMessageQuota caps messages created per UTC day with this key;
+	// 0 uses the deployment default
+	DailyMessageQuota int64     `json:"daily_message_quota" gorm:"default:0"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Active reports whether the key may be used at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
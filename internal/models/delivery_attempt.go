@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AttemptSent   = "sent"
+	AttemptFailed = "failed"
+)
+
+// DeliveryAttempt records one request to a gateway to send a message: which
+// provider handled it, how it ended and what it cost. Cost is unset when the
+// provider has no price for the recipient.
+type DeliveryAttempt struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	MessageID  uint      `json:"message_id" gorm:"not null;index"`
+	TenantID   uint      `json:"tenant_id" gorm:"not null;default:1;index"`
+	Provider   string    `json:"provider" gorm:"not null;size:100"`
+	Status     string    `json:"status" gorm:"not null;size:16"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty" gorm:"size:32"`
+	Cost       *float64  `json:"cost,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// DeliveryReceipt is a raw delivery report received from a gateway, kept for audit
+type DeliveryReceipt struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	Provider          string    `json:"provider" gorm:"not null"`
+	ProviderMessageID string    `json:"provider_message_id" gorm:"not null;index"`
+	MessageID         *uint     `json:"message_id,omitempty" gorm:"index"`
+	Status            string    `json:"status"`
+	RawPayload        string    `json:"raw_payload" gorm:"type:text;not null"`
+	CreatedAt         time.Time `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key so retries of it can be answered without repeating it.
+// A record that is not Completed is a claim held while the first request
+// is processed.
+type IdempotencyRecord struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	TenantID uint   `json:"tenant_id" gorm:"not null;uniqueIndex:idx_idempotency_records_tenant_key"`
+	Key      string `json:"key" gorm:"not null;size:255;uniqueIndex:idx_idempotency_records_tenant_key"`
+	// RequestHash identifies the request the key was first used with
+	RequestHash  string    `json:"request_hash" gorm:"not null;size:64"`
+	Completed    bool      `json:"completed" gorm:"default:false"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty" gorm:"type:text"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// InboundMessage is a reply (MO message) received from a recipient
+type InboundMessage struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	TenantID          uint      `json:"tenant_id" gorm:"not null;default:1;index"`
+	Provider          string    `json:"provider" gorm:"not null"`
+	ProviderMessageID string    `json:"provider_message_id" gorm:"index"`
+	From              string    `json:"from" gorm:"not null;index"`
+	To                string    `json:"to"`
+	Content           string    `json:"content" gorm:"type:text"`
+	Keyword           string    `json:"keyword,omitempty"`
+	RelatedMessageID  *uint     `json:"related_message_id,omitempty" gorm:"index"`
+	ReceivedAt        time.Time `json:"received_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
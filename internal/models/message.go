@@ -4,13 +4,92 @@ import (
 	"time"
 )
 
+const (
+	StatusPending      = "pending"
+	StatusSending      = "sending"
+	StatusSent         = "sent"
+	StatusFailed       = "failed"
+	StatusDeadLettered = "dead_lettered"
+	StatusSuppressed   = "suppressed"
+	StatusNeedsReview  = "needs_review"
+
+	DeliveryStatusDelivered     = "delivered"
+	DeliveryStatusUndeliverable = "undeliverable"
+	DeliveryStatusExpired       = "expired"
+
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+// Attachment is a file sent with an email. Content is base64 encoded.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+// Fallback is the next channel to try when a message is not delivered.
+// Content defaults to the content of the attempt it follows.
+type Fallback struct {
+	Channel  string `json:"channel"`
+	To       string `json:"to"`
+	Content  string `json:"content,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	HTMLBody string `json:"html_body,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// Message is one SMS, email or push notification. To is an E.164 number,
+// an email address or a device token depending on the channel; Content is
+// the SMS text, the plain text email body or the push body. Subject,
+// HTMLBody and Attachments only apply to email and Title and Data to push.
+//
+// A message with Fallbacks is the first attempt of a logical message: when
+// it fails permanently, or no delivery receipt arrives within
+// FallbackTimeout seconds of sending, the next attempt is created from
+// Fallbacks[0] and carries the rest of the chain. Later attempts point at
+// the first through OriginalID, and each attempt at the one created after
+// it through FallbackID, which is only ever set by the fallback itself.
+//
+// An OptOutConfirmation answers a STOP keyword and is the one message sent
+// to a recipient who has just been suppressed.
 type Message struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	To        string    `json:"to" gorm:"not null"`
-	Content   string    `json:"content" gorm:"not null;size:160"`
-	Sent      bool      `json:"sent" gorm:"default:false"`
-	SentAt    time.Time `json:"sent_at,omitempty"`
-	MessageID string    `json:"message_id,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 uint              `json:"id" gorm:"primaryKey"`
+	TenantID           uint              `json:"tenant_id" gorm:"not null;default:1;index"`
+	Channel            string            `json:"channel" gorm:"size:8;not null;default:sms;index"`
+	To                 string            `json:"to" gorm:"not null"`
+	CountryCode        int               `json:"country_code,omitempty"`
+	Region             string            `json:"region,omitempty" gorm:"size:2;index"`
+	Content            string            `json:"content" gorm:"not null"`
+	Subject            string            `json:"subject,omitempty"`
+	HTMLBody           string            `json:"html_body,omitempty"`
+	Attachments        []Attachment      `json:"attachments,omitempty" gorm:"type:jsonb;serializer:json"`
+	Title              string            `json:"title,omitempty"`
+	Data               map[string]string `json:"data,omitempty" gorm:"type:jsonb;serializer:json"`
+	Encoding           string            `json:"encoding,omitempty" gorm:"size:8"`
+	Segments           int               `json:"segments" gorm:"default:1"`
+	Sent               bool              `json:"sent" gorm:"default:false"`
+	SentAt             time.Time         `json:"sent_at,omitempty"`
+	MessageID          string            `json:"message_id,omitempty" gorm:"index"`
+	Provider           string            `json:"provider,omitempty" gorm:"size:100"`
+	Status             string            `json:"status" gorm:"not null;default:pending;index"`
+	Attempts           int               `json:"attempts" gorm:"default:0"`
+	LastError          string            `json:"last_error,omitempty"`
+	CallbackURL        string            `json:"callback_url,omitempty"`
+	TemplateID         *uint             `json:"template_id,omitempty" gorm:"index"`
+	OriginalID         *uint             `json:"original_id,omitempty" gorm:"index"`
+	FallbackID         *uint             `json:"fallback_id,omitempty" gorm:"<-:create"`
+	Fallbacks          []Fallback        `json:"fallbacks,omitempty" gorm:"type:jsonb;serializer:json"`
+	FallbackTimeout    int               `json:"fallback_timeout,omitempty"`
+	DeliveryStatus     string            `json:"delivery_status,omitempty"`
+	DeliveredAt        *time.Time        `json:"delivered_at,omitempty"`
+	NextAttemptAt      *time.Time        `json:"next_attempt_at,omitempty" gorm:"index"`
+	SendToken          string            `json:"send_token,omitempty" gorm:"size:36;index"`
+	SendingAt          *time.Time        `json:"sending_at,omitempty"`
+	ReconcileAfter     *time.Time        `json:"-"`
+	TraceParent        string            `json:"-" gorm:"size:55"`
+	OptOutConfirmation bool              `json:"opt_out_confirmation,omitempty" gorm:"default:false"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	EventMessageCreated       = "message.created"
+	EventMessageSent          = "message.sent"
+	EventMessageFailed        = "message.failed"
+	EventMessageDeadLettered  = "message.dead_lettered"
+	EventMessageDelivered     = "message.delivered"
+	EventMessageUndeliverable = "message.undeliverable"
+	EventMessageExpired       = "message.expired"
+	EventMessageInbound       = "message.inbound"
+	EventMessageSuppressed    = "message.suppressed"
+	EventMessageNeedsReview   = "message.needs_review"
+	EventMessageFallback      = "message.fallback"
+)
+
+// OutboxEvent is a message event recorded in the same transaction as the
+// message change it describes. The outbox relay claims pending events until
+// ClaimedUntil, publishes them and marks them dispatched; an event that
+// keeps failing to publish is given up on at FailedAt.
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	AggregateType string     `json:"aggregate_type" gorm:"not null"`
+	AggregateID   uint       `json:"aggregate_id" gorm:"not null;index"`
+	EventType     string     `json:"event_type" gorm:"not null"`
+	Payload       string     `json:"payload" gorm:"type:jsonb;not null"`
+	Dispatched    bool       `json:"dispatched" gorm:"default:false;index"`
+	DispatchedAt  *time.Time `json:"dispatched_at,omitempty"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"last_error,omitempty"`
+	ClaimedUntil  *time.Time `json:"claimed_until,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
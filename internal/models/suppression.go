@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	SuppressionReasonOptOut    = "opt_out"
+	SuppressionReasonComplaint = "complaint"
+	SuppressionReasonBounce    = "bounce"
+	SuppressionReasonManual    = "manual"
+)
+
+// Suppression blocks a tenant's messages to a recipient until it expires
+type Suppression struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	TenantID  uint       `json:"tenant_id" gorm:"not null;default:1;uniqueIndex:idx_suppressions_tenant_recipient"`
+	Recipient string     `json:"recipient" gorm:"not null;uniqueIndex:idx_suppressions_tenant_recipient"`
+	Reason    string     `json:"reason" gorm:"not null"`
+	Note      string     `json:"note,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the suppression still applies at the given time
+func (s *Suppression) Active(now time.Time) bool {
+	return s.ExpiresAt == nil || s.ExpiresAt.After(now)
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Template is a message body with {{variable}} placeholders. Versions are
+// immutable: saving a template under a name that exists adds a version.
+type Template struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	TenantID  uint              `json:"tenant_id" gorm:"not null;default:1;uniqueIndex:idx_templates_tenant_name_version"`
+	Name      string            `json:"name" gorm:"not null;size:100;uniqueIndex:idx_templates_tenant_name_version"`
+	Version   int               `json:"version" gorm:"not null;uniqueIndex:idx_templates_tenant_name_version"`
+	Body      string            `json:"body" gorm:"not null"`
+	Variants  []TemplateVariant `json:"variants,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// TemplateVariant is a template's body for one locale, used instead of the
+// default body when a message asks for that locale
+type TemplateVariant struct {
+	ID         uint   `json:"-" gorm:"primaryKey"`
+	TemplateID uint   `json:"-" gorm:"not null;uniqueIndex:idx_template_variants_template_locale"`
+	Locale     string `json:"locale" gorm:"not null;size:35;uniqueIndex:idx_template_variants_template_locale"`
+	Body       string `json:"body" gorm:"not null"`
+}
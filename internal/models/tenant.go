@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultTenantID is the tenant that owns data created before multi-tenancy
+// and inbound messages that cannot be matched to a tenant
+const DefaultTenantID uint = 1
+
+// Tenant is a product team sharing this deployment. Its messages, API keys
+// and webhooks are isolated from other tenants.
+type Tenant struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"not null;uniqueIndex"`
+	// Provider names the gateway this tenant sends through; empty uses the default
+	Provider string `json:"provider,omitempty"`
+	// RateLimit overrides the per-recipient limit, as "<algorithm>:<limit>/<window>"
+	RateLimit string `json:"rate_limit,omitempty"`
+	// DailyQuota caps messages sent per UTC day; 0 means unlimited
+	DailyQuota int       `json:"daily_quota" gorm:"default:0"`
+	Active     bool      `json:"active" gorm:"default:true"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
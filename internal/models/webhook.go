@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is a client callback URL notified of message status changes
+type WebhookSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null;default:1;index"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-" gorm:"not null"`
+	Events    string    `json:"events"`
+	Active    bool      `json:"active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one status-change callback and the log of its attempts
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	SubscriptionID *uint      `json:"subscription_id,omitempty" gorm:"index"`
+	MessageID      uint       `json:"message_id" gorm:"not null;index"`
+	URL            string     `json:"url" gorm:"not null"`
+	EventType      string     `json:"event_type" gorm:"not null"`
+	Payload        string     `json:"payload" gorm:"type:jsonb;not null"`
+	Status         string     `json:"status" gorm:"not null;default:pending;index"`
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	ResponseCode   int        `json:"response_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
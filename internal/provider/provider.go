@@ -0,0 +1,356 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/redis"
+	"github.com/vkukul/messaging-system/pkg/signing"
+)
+
+const (
+	DefaultName = "default"
+	defaultURL  = "https://httpbin.org/post"
+
+	TimestampHeader = "X-Signature-Timestamp"
+	SignatureHeader = "X-Signature"
+	// IdempotencyHeader carries a message's send token so the gateway can
+	// drop a repeated send of the same message
+	IdempotencyHeader = "Idempotency-Key"
+
+	// statusKeyPlaceholder is replaced with the send token in StatusURL
+	statusKeyPlaceholder = "{key}"
+
+	inboundTolerance = 5 * time.Minute
+)
+
+// SigningKey is an HMAC key used to sign outbound requests
+type SigningKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// channels are the message channels a gateway can serve
+var channels = map[string]bool{
+	models.ChannelSMS:   true,
+	models.ChannelEmail: true,
+	models.ChannelPush:  true,
+}
+
+// Config describes an outbound message gateway and how to authenticate to it
+type Config struct {
+	Name string `json:"name"`
+	// Channel is the kind of message the gateway sends: sms (the default),
+	// email or push
+	Channel     string            `json:"channel,omitempty"`
+	URL         string            `json:"url"`
+	BearerToken string            `json:"bearer_token,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	// SigningKeys are all active keys. Requests carry a signature for each
+	// key so receivers can rotate without rejecting traffic.
+	SigningKeys []SigningKey `json:"signing_keys,omitempty"`
+	// InboundSecret verifies callbacks the gateway sends to us
+	InboundSecret string `json:"inbound_secret,omitempty"`
+	// RateLimit caps our send rate to this gateway across all replicas, as
+	// "<algorithm>:<limit>/<window>". Empty uses RATE_LIMIT_PROVIDER.
+	RateLimit string `json:"rate_limit,omitempty"`
+	// StatusURL looks up a message the gateway received by its idempotency
+	// key, which replaces "{key}". Without it messages whose send was never
+	// recorded are left for review.
+	StatusURL string `json:"status_url,omitempty"`
+	// Costs is the price of one message, or one SMS segment, by destination
+	// prefix: E.164 digits without the "+", or "*" for any destination
+	Costs map[string]float64 `json:"costs,omitempty"`
+}
+
+var (
+	mu        sync.RWMutex
+	providers []*Config
+)
+
+// Init loads provider configs from the JSON file named by PROVIDERS_FILE, or
+// builds a single default provider from the PROVIDER_* variables
+func Init() error {
+	configs, err := Load()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	providers = configs
+	return nil
+}
+
+// Load reads provider configs without installing them
+func Load() ([]*Config, error) {
+	path := getEnv("PROVIDERS_FILE", "")
+	if path == "" {
+		cfg, err := fromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return []*Config{cfg}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read providers file: %v", err)
+	}
+
+	var configs []*Config
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse providers file: %v", err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("providers file %s defines no providers", path)
+	}
+	for _, cfg := range configs {
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+// Default returns the first configured SMS provider, or the first provider
+// if none sends SMS
+func Default() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, cfg := range providers {
+		if cfg.ServesChannel(models.ChannelSMS) {
+			return cfg
+		}
+	}
+	if len(providers) > 0 {
+		return providers[0]
+	}
+
+	// Not initialised (e.g. in tests); fall back to the environment
+	cfg, err := fromEnv()
+	if err != nil {
+		return &Config{Name: DefaultName, URL: defaultURL}
+	}
+	return cfg
+}
+
+// All returns every configured provider in configuration order
+func All() []*Config {
+	mu.RLock()
+	configs := providers
+	mu.RUnlock()
+	if len(configs) == 0 {
+		return []*Config{Default()}
+	}
+	return configs
+}
+
+// Get returns the provider with the given name
+func Get(name string) (*Config, bool) {
+	for _, cfg := range All() {
+		if cfg.Name == name {
+			return cfg, true
+		}
+	}
+	return nil, false
+}
+
+// ForChannel returns the first provider serving a channel
+func ForChannel(channel string) (*Config, bool) {
+	configs := Serving(channel)
+	if len(configs) == 0 {
+		return nil, false
+	}
+	return configs[0], true
+}
+
+// Serving returns every provider serving a channel in configuration order
+func Serving(channel string) []*Config {
+	var serving []*Config
+	for _, cfg := range All() {
+		if cfg.ServesChannel(channel) {
+			serving = append(serving, cfg)
+		}
+	}
+	return serving
+}
+
+// Cost returns the gateway's price for sending to a recipient, using the
+// entry for the longest matching prefix of an E.164 number or else "*". ok
+// is false when the gateway has a cost table without a price for the
+// recipient, i.e. it does not serve it. Gateways without a cost table serve
+// every recipient at an unknown cost, returned as +Inf so that priced
+// gateways are preferred.
+func (c *Config) Cost(recipient string) (cost float64, ok bool) {
+	if len(c.Costs) == 0 {
+		return math.Inf(1), true
+	}
+	if digits, isE164 := strings.CutPrefix(recipient, "+"); isE164 {
+		for length := len(digits); length > 0; length-- {
+			if cost, ok := c.Costs[digits[:length]]; ok {
+				return cost, true
+			}
+		}
+	}
+	cost, ok = c.Costs["*"]
+	return cost, ok
+}
+
+// ValidChannel reports whether a channel is one messages can be sent on
+func ValidChannel(channel string) bool {
+	return channels[channel]
+}
+
+// ServesChannel reports whether the gateway sends messages of a channel
+func (c *Config) ServesChannel(channel string) bool {
+	if c.Channel == "" {
+		return channel == models.ChannelSMS
+	}
+	return c.Channel == channel
+}
+
+// VerifyInbound checks the signature on a callback sent by the gateway. The
+// gateway signs "<timestamp>.<body>" with the inbound secret.
+func (c *Config) VerifyInbound(timestamp, signature string, body []byte) error {
+	if c.InboundSecret == "" {
+		return fmt.Errorf("provider %s has no inbound secret configured", c.Name)
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+	return signing.Verify(c.InboundSecret, ts, body, signature, inboundTolerance)
+}
+
+// Authorize adds the provider's static headers, bearer token and request
+// signatures to an outbound request
+func (c *Config) Authorize(req *http.Request, body []byte, now time.Time) {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+
+	if len(c.SigningKeys) == 0 {
+		return
+	}
+	timestamp := now.Unix()
+	signatures := make([]string, 0, len(c.SigningKeys))
+	for _, key := range c.SigningKeys {
+		signatures = append(signatures, key.ID+"="+signing.Sign(key.Secret, timestamp, body))
+	}
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, strings.Join(signatures, ","))
+}
+
+func (c *Config) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("provider name cannot be empty")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("provider %s: url cannot be empty", c.Name)
+	}
+	if c.Channel != "" && !channels[c.Channel] {
+		return fmt.Errorf("provider %s: unknown channel %q", c.Name, c.Channel)
+	}
+	for _, key := range c.SigningKeys {
+		if key.ID == "" || key.Secret == "" {
+			return fmt.Errorf("provider %s: signing keys need an id and a secret", c.Name)
+		}
+	}
+	if c.RateLimit != "" {
+		if _, err := redis.ParseRateLimit(c.RateLimit); err != nil {
+			return fmt.Errorf("provider %s: invalid rate_limit: %v", c.Name, err)
+		}
+	}
+	for prefix, cost := range c.Costs {
+		if prefix == "" || (prefix != "*" && strings.Trim(prefix, "0123456789") != "") {
+			return fmt.Errorf("provider %s: cost prefix %q must be digits or *", c.Name, prefix)
+		}
+		if cost < 0 || math.IsNaN(cost) {
+			return fmt.Errorf("provider %s: cost for %s cannot be negative", c.Name, prefix)
+		}
+	}
+	if c.StatusURL != "" && !strings.Contains(c.StatusURL, statusKeyPlaceholder) {
+		return fmt.Errorf("provider %s: status_url must contain %s", c.Name, statusKeyPlaceholder)
+	}
+	return nil
+}
+
+// MessageStatusURL returns where to look up the message sent with the given
+// idempotency key, or "" if the gateway cannot be queried
+func (c *Config) MessageStatusURL(key string) string {
+	if c.StatusURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(c.StatusURL, statusKeyPlaceholder, url.PathEscape(key))
+}
+
+// Throughput returns the limiter for sends to this gateway
+func (c *Config) Throughput() (redis.RateLimit, error) {
+	if c.RateLimit == "" {
+		return redis.GetRateLimit(redis.ClassProvider)
+	}
+	return redis.ParseRateLimit(c.RateLimit)
+}
+
+// fromEnv builds the default provider. PROVIDER_HEADERS is a comma separated
+// list of Name=value pairs and PROVIDER_SIGNING_KEYS a comma separated list
+// of id:secret pairs.
+func fromEnv() (*Config, error) {
+	cfg := &Config{
+		Name:          getEnv("PROVIDER_NAME", DefaultName),
+		URL:           getEnv("PROVIDER_URL", defaultURL),
+		BearerToken:   getEnv("PROVIDER_BEARER_TOKEN", ""),
+		InboundSecret: getEnv("PROVIDER_INBOUND_SECRET", ""),
+		RateLimit:     getEnv("PROVIDER_RATE_LIMIT", ""),
+		StatusURL:     getEnv("PROVIDER_STATUS_URL", ""),
+	}
+
+	if headers := getEnv("PROVIDER_HEADERS", ""); headers != "" {
+		cfg.Headers = make(map[string]string)
+		for _, pair := range strings.Split(headers, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid PROVIDER_HEADERS entry %q", pair)
+			}
+			cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+
+	if keys := getEnv("PROVIDER_SIGNING_KEYS", ""); keys != "" {
+		for _, pair := range strings.Split(keys, ",") {
+			id, secret, ok := strings.Cut(pair, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid PROVIDER_SIGNING_KEYS entry %q", pair)
+			}
+			cfg.SigningKeys = append(cfg.SigningKeys, SigningKey{
+				ID:     strings.TrimSpace(id),
+				Secret: strings.TrimSpace(secret),
+			})
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/redis"
+	"github.com/vkukul/messaging-system/pkg/signing"
+)
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("PROVIDERS_FILE", "")
+	t.Setenv("PROVIDER_URL", "https://gateway.example.com/sms")
+	t.Setenv("PROVIDER_BEARER_TOKEN", "token")
+	t.Setenv("PROVIDER_HEADERS", "X-Account=42, X-Api-Key=abc")
+	t.Setenv("PROVIDER_SIGNING_KEYS", "k2:new-secret,k1:old-secret")
+
+	configs, err := Load()
+	assert.NoError(t, err)
+	assert.Len(t, configs, 1)
+
+	cfg := configs[0]
+	assert.Equal(t, DefaultName, cfg.Name)
+	assert.Equal(t, "https://gateway.example.com/sms", cfg.URL)
+	assert.Equal(t, "token", cfg.BearerToken)
+	assert.Equal(t, map[string]string{"X-Account": "42", "X-Api-Key": "abc"}, cfg.Headers)
+	assert.Equal(t, []SigningKey{{ID: "k2", Secret: "new-secret"}, {ID: "k1", Secret: "old-secret"}}, cfg.SigningKeys)
+}
+
+func TestLoadFromFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "Valid providers",
+			content: `[{"name":"primary","url":"https://a.example.com"},{"name":"secondary","url":"https://b.example.com","signing_keys":[{"id":"k1","secret":"s"}]}]`,
+			wantErr: false,
+		},
+		{
+			name:    "Missing url",
+			content: `[{"name":"primary"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Incomplete signing key",
+			content: `[{"name":"primary","url":"https://a.example.com","signing_keys":[{"id":"k1"}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Status url without key",
+			content: `[{"name":"primary","url":"https://a.example.com","status_url":"https://a.example.com/messages"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid cost prefix",
+			content: `[{"name":"primary","url":"https://a.example.com","costs":{"+90":0.01}}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Negative cost",
+			content: `[{"name":"primary","url":"https://a.example.com","costs":{"90":-1}}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown channel",
+			content: `[{"name":"primary","url":"https://a.example.com","channel":"fax"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "No providers",
+			content: `[]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			t.Setenv("PROVIDERS_FILE", path)
+
+			configs, err := Load()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, configs, 2)
+			assert.Equal(t, "secondary", configs[1].Name)
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	cfg := &Config{
+		Name:        "primary",
+		URL:         "https://gateway.example.com/sms",
+		BearerToken: "token",
+		Headers:     map[string]string{"X-Account": "42"},
+		SigningKeys: []SigningKey{{ID: "k2", Secret: "new-secret"}, {ID: "k1", Secret: "old-secret"}},
+	}
+	body := []byte(`{"to":"+905551234567","content":"Test message"}`)
+	now := time.Unix(1700000000, 0)
+
+	req, _ := http.NewRequest("POST", cfg.URL, nil)
+	cfg.Authorize(req, body, now)
+
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	assert.Equal(t, "42", req.Header.Get("X-Account"))
+	assert.Equal(t, "1700000000", req.Header.Get(TimestampHeader))
+
+	signatures := strings.Split(req.Header.Get(SignatureHeader), ",")
+	assert.Equal(t, []string{
+		"k2=" + signing.Sign("new-secret", now.Unix(), body),
+		"k1=" + signing.Sign("old-secret", now.Unix(), body),
+	}, signatures)
+}
+
+func TestAuthorizeWithoutCredentials(t *testing.T) {
+	cfg := &Config{Name: "primary", URL: "https://gateway.example.com/sms"}
+
+	req, _ := http.NewRequest("POST", cfg.URL, nil)
+	cfg.Authorize(req, []byte(`{}`), time.Now())
+
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Empty(t, req.Header.Get(TimestampHeader))
+	assert.Empty(t, req.Header.Get(SignatureHeader))
+}
+
+func TestVerifyInbound(t *testing.T) {
+	body := []byte(`{"message_id":"abc","status":"delivered"}`)
+	now := time.Now().Unix()
+	timestamp := strconv.FormatInt(now, 10)
+
+	cfg := &Config{Name: "primary", InboundSecret: "inbound"}
+	assert.NoError(t, cfg.VerifyInbound(timestamp, signing.Sign("inbound", now, body), body))
+	assert.Error(t, cfg.VerifyInbound(timestamp, signing.Sign("other", now, body), body))
+	assert.Error(t, cfg.VerifyInbound("not-a-number", signing.Sign("inbound", now, body), body))
+
+	unsigned := &Config{Name: "primary"}
+	assert.Error(t, unsigned.VerifyInbound(timestamp, signing.Sign("", now, body), body))
+}
+
+func TestThroughput(t *testing.T) {
+	t.Setenv("RATE_LIMIT_PROVIDER", "")
+
+	cfg := &Config{Name: "primary", URL: "https://gateway.example.com/sms"}
+	limit, err := cfg.Throughput()
+	assert.NoError(t, err)
+	assert.Equal(t, redis.RateLimit{Algorithm: redis.AlgorithmTokenBucket, Limit: 50, Window: time.Second}, limit)
+
+	cfg.RateLimit = "token_bucket:20/1s"
+	limit, err = cfg.Throughput()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), limit.Limit)
+
+	cfg.RateLimit = "fast"
+	assert.Error(t, cfg.validate())
+}
+
+func TestMessageStatusURL(t *testing.T) {
+	cfg := &Config{Name: "primary", URL: "https://gateway.example.com/sms"}
+	assert.Empty(t, cfg.MessageStatusURL("token"))
+
+	cfg.StatusURL = "https://gateway.example.com/sms/by-key/{key}"
+	assert.NoError(t, cfg.validate())
+	assert.Equal(t, "https://gateway.example.com/sms/by-key/a%2Fb", cfg.MessageStatusURL("a/b"))
+}
+
+func TestForChannel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	content := `[{"name":"mail","channel":"email","url":"https://mail.example.com"},{"name":"primary","url":"https://sms.example.com"}]`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("PROVIDERS_FILE", path)
+	assert.NoError(t, Init())
+	t.Cleanup(func() {
+		mu.Lock()
+		providers = nil
+		mu.Unlock()
+	})
+
+	assert.Equal(t, "primary", Default().Name)
+
+	cfg, ok := ForChannel(models.ChannelEmail)
+	assert.True(t, ok)
+	assert.Equal(t, "mail", cfg.Name)
+
+	cfg, ok = ForChannel(models.ChannelSMS)
+	assert.True(t, ok)
+	assert.Equal(t, "primary", cfg.Name)
+
+	_, ok = ForChannel(models.ChannelPush)
+	assert.False(t, ok)
+
+	assert.Len(t, Serving(models.ChannelSMS), 1)
+	assert.Empty(t, Serving(models.ChannelPush))
+}
+
+func TestCost(t *testing.T) {
+	cfg := &Config{Name: "primary", Costs: map[string]float64{"90": 0.02, "9053": 0.01, "*": 0.05}}
+
+	tests := []struct {
+		name      string
+		recipient string
+		want      float64
+	}{
+		{name: "Longest prefix", recipient: "+905321234567", want: 0.01},
+		{name: "Country prefix", recipient: "+902121234567", want: 0.02},
+		{name: "Any destination", recipient: "+441234567890", want: 0.05},
+		{name: "Not a phone number", recipient: "jane@example.com", want: 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, ok := cfg.Cost(tt.recipient)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, cost)
+		})
+	}
+
+	delete(cfg.Costs, "*")
+	_, ok := cfg.Cost("+441234567890")
+	assert.False(t, ok)
+
+	unpriced := &Config{Name: "unpriced"}
+	cost, ok := unpriced.Cost("+441234567890")
+	assert.True(t, ok)
+	assert.True(t, math.IsInf(cost, 1))
+}
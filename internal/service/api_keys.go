@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+const (
+	apiKeyPrefix       = "msk_"
+	apiKeyRandomBytes  = 32
+	apiKeyDisplayChars = 12
+	// bootstrapKeyName names the key created from API_BOOTSTRAP_KEY
+	bootstrapKeyName      = "bootstrap"
+	minBootstrapKeyLength = 32
+)
+
+// apiKeyScopes are the scopes a key can be granted
+var apiKeyScopes = map[string]bool{
+	models.ScopeMessagesWrite:  true,
+	models.ScopeMessagesRead:   true,
+	models.ScopeProcessorAdmin: true,
+	models.ScopeKeysAdmin:      true,
+	models.ScopeTenantsAdmin:   true,
+}
+
+// operatorScopes can only be granted to and used by keys of the default
+// tenant, which operates the deployment
+var operatorScopes = map[string]bool{
+	// The processor is shared by every tenant
+	models.ScopeProcessorAdmin: true,
+	models.ScopeTenantsAdmin:   true,
+}
+
+// ErrInvalidAPIKey is returned when a key request fails validation
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrScopeNotGranted is returned when a key asks for a scope the key issuing
+// it does not hold
+var ErrScopeNotGranted = errors.New("scope not granted")
+
+// ErrAPIKeyNotFound is returned when a key does not exist
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrUnauthenticated is returned when a presented key is unknown, expired or revoked
+var ErrUnauthenticated = errors.New("invalid or missing API key")
+
+// APIKeyService issues, revokes and verifies API keys
+type APIKeyService struct{}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{}
+}
+
+// CreateKey issues a key for a tenant with the given scopes. A
+// dailyMessageQuota of 0 uses the deployment default. The plaintext key is
+// only returned here; afterwards only its hash is known.
+func (s *APIKeyService) CreateKey(tenantID uint, name string, scopes []string, expiresAt *time.Time, dailyMessageQuota int64) (*models.APIKey, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name cannot be empty", ErrInvalidAPIKey)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, scope)
+		}
+		if operatorScopes[scope] && tenantID != models.DefaultTenantID {
+			return nil, "", fmt.Errorf("%w: scope %q is only available to the default tenant", ErrInvalidAPIKey, scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+	if dailyMessageQuota < 0 {
+		return nil, "", fmt.Errorf("%w: daily_message_quota cannot be negative", ErrInvalidAPIKey)
+	}
+
+	raw, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		TenantID:          tenantID,
+		Name:              name,
+		Prefix:            raw[:apiKeyDisplayChars],
+		KeyHash:           hashAPIKey(raw),
+		Scopes:            strings.Join(scopes, ","),
+		ExpiresAt:         expiresAt,
+		DailyMessageQuota: dailyMessageQuota,
+	}
+	if err := database.DB.Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("error creating API key: %v", err)
+	}
+	return key, raw, nil
+}
+
+func (s *APIKeyService) ListKeys(tenantID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := database.DB.Where("tenant_id = ?", tenantID).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("error fetching API keys: %v", err)
+	}
+	return keys, nil
+}
+
+// GetKey returns one of a tenant's keys
+func (s *APIKeyService) GetKey(tenantID, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := database.DB.Where("tenant_id = ?", tenantID).First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching API key: %v", err)
+	}
+	return &key, nil
+}
+
+// RevokeKey disables a key. Revoked keys are kept so their usage stays visible.
+func (s *APIKeyService) RevokeKey(tenantID, id uint) error {
+	result := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND tenant_id = ? AND revoked_at IS NULL", id, tenantID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("error revoking API key: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the active key matching raw and records its use
+func (s *APIKeyService) Authenticate(ctx context.Context, raw string) (*models.APIKey, error) {
+	if raw == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	var key models.APIKey
+	err := database.DB.WithContext(ctx).Where("key_hash = ?", hashAPIKey(raw)).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up API key: %v", err)
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, ErrUnauthenticated
+	}
+
+	// Usage tracking is best effort and never fails the request
+	if err := database.DB.WithContext(ctx).Model(&key).UpdateColumns(map[string]interface{}{
+		"usage_count":  gorm.Expr("usage_count + 1"),
+		"last_used_at": now,
+	}).Error; err != nil {
+		slog.WarnContext(ctx, "Failed to record API key usage", "api_key_id", key.ID, "error", err)
+	}
+	return &key, nil
+}
+
+// EnsureBootstrapKey stores raw as a default tenant key with every scope
+// unless it already exists, so a fresh deployment has a key to create the
+// others and further tenants with
+func (s *APIKeyService) EnsureBootstrapKey(raw string) error {
+	if len(raw) < minBootstrapKeyLength {
+		return fmt.Errorf("%w: bootstrap key must be at least %d characters", ErrInvalidAPIKey, minBootstrapKeyLength)
+	}
+
+	key := models.APIKey{
+		TenantID: models.DefaultTenantID,
+		Name:     bootstrapKeyName,
+		Prefix:   raw[:apiKeyDisplayChars],
+		KeyHash:  hashAPIKey(raw),
+		Scopes: strings.Join([]string{
+			models.ScopeMessagesWrite,
+			models.ScopeMessagesRead,
+			models.ScopeProcessorAdmin,
+			models.ScopeKeysAdmin,
+			models.ScopeTenantsAdmin,
+		}, ","),
+	}
+	if err := database.DB.Where("key_hash = ?", key.KeyHash).FirstOrCreate(&key).Error; err != nil {
+		return fmt.Errorf("error creating bootstrap API key: %v", err)
+	}
+	return nil
+}
+
+// CanGrant checks that issuer holds every scope it wants to give a new key,
+// so keys cannot be used to create more powerful ones
+func CanGrant(issuer *models.APIKey, scopes []string) error {
+	for _, scope := range scopes {
+		if !HasScope(issuer, scope) {
+			return fmt.Errorf("%w: API key lacks the %s scope", ErrScopeNotGranted, scope)
+		}
+	}
+	return nil
+}
+
+// HasScope reports whether a key was granted scope. Operator scopes only
+// count on keys of the default tenant.
+func HasScope(key *models.APIKey, scope string) bool {
+	if operatorScopes[scope] && key.TenantID != models.DefaultTenantID {
+		return false
+	}
+	for _, granted := range strings.Split(key.Scopes, ",") {
+		if strings.TrimSpace(granted) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, apiKeyRandomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating API key: %v", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a key. Keys carry 256 bits of
+// randomness, so a fast unsalted hash is enough and allows lookup by hash.
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
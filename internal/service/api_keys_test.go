@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func TestHasScope(t *testing.T) {
+	key := &models.APIKey{Scopes: "messages:read,messages:write"}
+	assert.True(t, HasScope(key, models.ScopeMessagesRead))
+	assert.True(t, HasScope(key, models.ScopeMessagesWrite))
+	assert.False(t, HasScope(key, models.ScopeProcessorAdmin))
+	assert.False(t, HasScope(&models.APIKey{}, models.ScopeMessagesRead))
+
+	// Operator scopes only count on the default tenant
+	operator := &models.APIKey{TenantID: models.DefaultTenantID, Scopes: "tenants:admin"}
+	assert.True(t, HasScope(operator, models.ScopeTenantsAdmin))
+	tenant := &models.APIKey{TenantID: 2, Scopes: "processor:admin,tenants:admin"}
+	assert.False(t, HasScope(tenant, models.ScopeProcessorAdmin))
+	assert.False(t, HasScope(tenant, models.ScopeTenantsAdmin))
+}
+
+func TestCanGrant(t *testing.T) {
+	issuer := &models.APIKey{TenantID: models.DefaultTenantID, Scopes: "messages:read,keys:admin"}
+	assert.NoError(t, CanGrant(issuer, []string{models.ScopeMessagesRead}))
+	assert.NoError(t, CanGrant(issuer, []string{models.ScopeMessagesRead, models.ScopeKeysAdmin}))
+	assert.ErrorIs(t, CanGrant(issuer, []string{models.ScopeTenantsAdmin}), ErrScopeNotGranted)
+	assert.ErrorIs(t, CanGrant(issuer, []string{models.ScopeMessagesRead, models.ScopeProcessorAdmin}), ErrScopeNotGranted)
+}
+
+func TestCreateKeyValidation(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name      string
+		tenantID  uint
+		keyName   string
+		scopes    []string
+		expiresAt *time.Time
+	}{
+		{name: "Missing name", keyName: "", scopes: []string{models.ScopeMessagesRead}},
+		{name: "No scopes", keyName: "ci", scopes: nil},
+		{name: "Unknown scope", keyName: "ci", scopes: []string{"messages:delete"}},
+		{name: "Already expired", keyName: "ci", scopes: []string{models.ScopeMessagesRead}, expiresAt: &past},
+		{name: "Operator scope for another tenant", tenantID: 2, keyName: "ci", scopes: []string{models.ScopeTenantsAdmin}},
+		{name: "Processor admin for another tenant", tenantID: 2, keyName: "ci", scopes: []string{models.ScopeProcessorAdmin}},
+	}
+
+	service := NewAPIKeyService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantID := tt.tenantID
+			if tenantID == 0 {
+				tenantID = models.DefaultTenantID
+			}
+			_, _, err := service.CreateKey(tenantID, tt.keyName, tt.scopes, tt.expiresAt, 0)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	setupTest(t)
+	service := NewAPIKeyService()
+	ctx := context.Background()
+
+	key, raw, err := service.CreateKey(models.DefaultTenantID, "test", []string{models.ScopeMessagesRead}, nil, 0)
+	assert.NoError(t, err)
+	defer database.DB.Unscoped().Delete(key)
+
+	assert.True(t, strings.HasPrefix(raw, apiKeyPrefix))
+	assert.Equal(t, raw[:apiKeyDisplayChars], key.Prefix)
+	assert.NotContains(t, key.KeyHash, raw)
+
+	authenticated, err := service.Authenticate(ctx, raw)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+
+	var stored models.APIKey
+	assert.NoError(t, database.DB.First(&stored, key.ID).Error)
+	assert.Equal(t, int64(1), stored.UsageCount)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	_, err = service.Authenticate(ctx, raw+"x")
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	assert.NoError(t, service.RevokeKey(models.DefaultTenantID, key.ID))
+	_, err = service.Authenticate(ctx, raw)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.ErrorIs(t, service.RevokeKey(models.DefaultTenantID, key.ID), ErrAPIKeyNotFound)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/provider"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// circuitStateValues are the values of the provider_circuit_state gauge
+var circuitStateValues = map[string]float64{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+// CircuitOpenError is returned when a provider's circuit breaker holds back
+// a send. Like a rate limit it is not a failure: the message is deferred
+// without using up an attempt.
+type CircuitOpenError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for provider %s, retry in %v", e.Provider, e.RetryAfter)
+}
+
+// breakerSettings are the thresholds shared by every provider's breaker
+type breakerSettings struct {
+	// failures is how many failed sends in a row open the circuit
+	failures int
+	// openFor is how long an open circuit waits before letting a probe
+	// through, and how long a probe may take before another one is allowed
+	openFor time.Duration
+	// probes is how many probes must succeed in a row to close the circuit
+	probes int
+}
+
+var defaultBreakerSettings = breakerSettings{
+	failures: 5,
+	openFor:  30 * time.Second,
+	probes:   1,
+}
+
+// circuitBreaker is the state of one provider's breaker. retryAt is when an
+// open circuit lets a probe through, or when a half-open one lets another
+// probe through if the one in flight has not reported back.
+type circuitBreaker struct {
+	state     string
+	failures  int
+	successes int
+	retryAt   time.Time
+}
+
+// circuitBreakers tracks a breaker per provider in this replica
+type circuitBreakers struct {
+	mu       sync.Mutex
+	settings breakerSettings
+	breakers map[string]*circuitBreaker
+}
+
+var breakers = &circuitBreakers{
+	settings: defaultBreakerSettings,
+	breakers: make(map[string]*circuitBreaker),
+}
+
+// InitCircuitBreakers reads the breaker thresholds from
+// CIRCUIT_BREAKER_FAILURES, CIRCUIT_BREAKER_OPEN_TIMEOUT and
+// CIRCUIT_BREAKER_PROBES and closes every configured provider's circuit
+func InitCircuitBreakers() error {
+	settings, err := loadBreakerSettings()
+	if err != nil {
+		return err
+	}
+
+	breakers.mu.Lock()
+	defer breakers.mu.Unlock()
+	breakers.settings = settings
+	breakers.breakers = make(map[string]*circuitBreaker)
+	for _, cfg := range provider.All() {
+		metrics.ProviderCircuitState.WithLabelValues(cfg.Name).Set(circuitStateValues[CircuitClosed])
+	}
+	return nil
+}
+
+func loadBreakerSettings() (breakerSettings, error) {
+	settings := defaultBreakerSettings
+
+	value := getEnv("CIRCUIT_BREAKER_FAILURES", strconv.Itoa(settings.failures))
+	failures, err := strconv.Atoi(value)
+	if err != nil || failures < 1 {
+		return settings, fmt.Errorf("invalid CIRCUIT_BREAKER_FAILURES %q", value)
+	}
+	settings.failures = failures
+
+	value = getEnv("CIRCUIT_BREAKER_OPEN_TIMEOUT", settings.openFor.String())
+	openFor, err := time.ParseDuration(value)
+	if err != nil || openFor < time.Second {
+		return settings, fmt.Errorf("invalid CIRCUIT_BREAKER_OPEN_TIMEOUT %q", value)
+	}
+	settings.openFor = openFor
+
+	value = getEnv("CIRCUIT_BREAKER_PROBES", strconv.Itoa(settings.probes))
+	probes, err := strconv.Atoi(value)
+	if err != nil || probes < 1 {
+		return settings, fmt.Errorf("invalid CIRCUIT_BREAKER_PROBES %q", value)
+	}
+	settings.probes = probes
+	return settings, nil
+}
+
+// get returns a provider's breaker, closed until it is first used. The
+// caller holds the lock.
+func (b *circuitBreakers) get(name string) *circuitBreaker {
+	cb, ok := b.breakers[name]
+	if !ok {
+		cb = &circuitBreaker{state: CircuitClosed}
+		b.breakers[name] = cb
+	}
+	return cb
+}
+
+// available reports whether a send through a provider may be attempted: its
+// circuit is closed, or it is due to let a probe through
+func (b *circuitBreakers) available(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+	return cb.state == CircuitClosed || !time.Now().Before(cb.retryAt)
+}
+
+// retryAfter returns how long until a provider lets a send through
+func (b *circuitBreakers) retryAfter(name string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+	if cb.state == CircuitClosed {
+		return 0
+	}
+	if wait := time.Until(cb.retryAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// allow is called right before a request to a provider. A closed circuit
+// lets every request through; an open or half-open one lets a single probe
+// through once its wait is over.
+func (b *circuitBreakers) allow(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+	if cb.state == CircuitClosed {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(cb.retryAt) {
+		return false
+	}
+	if cb.state == CircuitOpen {
+		cb.successes = 0
+		b.transition(name, cb, CircuitHalfOpen)
+	}
+	cb.retryAt = now.Add(b.settings.openFor)
+	return true
+}
+
+// abandon hands back a probe that allow let through but that was not sent,
+// so the next message can probe straight away
+func (b *circuitBreakers) abandon(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+	if cb.state == CircuitHalfOpen {
+		cb.retryAt = time.Now()
+	}
+}
+
+// record counts the outcome of a request to a provider. Only errors showing
+// the provider is unavailable count as failures; any answer from it,
+// including a 4xx, shows it is up.
+func (b *circuitBreakers) record(name string, sendErr error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+
+	if !tripsBreaker(sendErr) {
+		cb.failures = 0
+		if cb.state != CircuitHalfOpen {
+			return
+		}
+		cb.successes++
+		if cb.successes >= b.settings.probes {
+			b.transition(name, cb, CircuitClosed)
+			return
+		}
+		// Let the next probe through straight away
+		cb.retryAt = time.Now()
+		return
+	}
+
+	switch cb.state {
+	case CircuitClosed:
+		cb.failures++
+		if cb.failures >= b.settings.failures {
+			b.open(name, cb)
+		}
+	case CircuitHalfOpen:
+		b.open(name, cb)
+	}
+}
+
+func (b *circuitBreakers) open(name string, cb *circuitBreaker) {
+	cb.failures = 0
+	cb.retryAt = time.Now().Add(b.settings.openFor)
+	b.transition(name, cb, CircuitOpen)
+	metrics.ProviderCircuitOpened.WithLabelValues(name).Inc()
+}
+
+func (b *circuitBreakers) transition(name string, cb *circuitBreaker, state string) {
+	slog.Info("Provider circuit changed state", "provider", name, "from", cb.state, "to", state)
+	cb.state = state
+	metrics.ProviderCircuitState.WithLabelValues(name).Set(circuitStateValues[state])
+}
+
+// status returns the state of a provider's breaker for the health report
+func (b *circuitBreakers) status(name string) ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.get(name)
+	status := ProviderHealth{Circuit: cb.state, Failures: cb.failures}
+	if cb.state != CircuitClosed {
+		retryAt := cb.retryAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// tripsBreaker reports whether a send error shows the provider is
+// unavailable: it answered 429 or a 5xx, timed out or could not be reached.
+// Cancelled requests say nothing about the provider.
+func tripsBreaker(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if canFailOver(err) {
+		return true
+	}
+	class := errorClass(err)
+	return class == errorClassServer || class == errorClassTimeout || class == errorClassNetwork
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+// useBreakers gives the test fresh circuit breakers with the given settings
+func useBreakers(t *testing.T, settings breakerSettings) {
+	previous := breakers
+	breakers = &circuitBreakers{settings: settings, breakers: make(map[string]*circuitBreaker)}
+	t.Cleanup(func() { breakers = previous })
+}
+
+func TestLoadBreakerSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    breakerSettings
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			want: defaultBreakerSettings,
+		},
+		{
+			name: "Configured",
+			env: map[string]string{
+				"CIRCUIT_BREAKER_FAILURES":     "3",
+				"CIRCUIT_BREAKER_OPEN_TIMEOUT": "1m",
+				"CIRCUIT_BREAKER_PROBES":       "2",
+			},
+			want: breakerSettings{failures: 3, openFor: time.Minute, probes: 2},
+		},
+		{
+			name:    "Zero failures",
+			env:     map[string]string{"CIRCUIT_BREAKER_FAILURES": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid timeout",
+			env:     map[string]string{"CIRCUIT_BREAKER_OPEN_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "Timeout too short",
+			env:     map[string]string{"CIRCUIT_BREAKER_OPEN_TIMEOUT": "100ms"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid probes",
+			env:     map[string]string{"CIRCUIT_BREAKER_PROBES": "-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CIRCUIT_BREAKER_FAILURES", "CIRCUIT_BREAKER_OPEN_TIMEOUT", "CIRCUIT_BREAKER_PROBES"} {
+				t.Setenv(key, "")
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			settings, err := loadBreakerSettings()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, settings)
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	useBreakers(t, breakerSettings{failures: 3, openFor: time.Second, probes: 2})
+	unavailable := &gatewayStatusError{StatusCode: 503}
+
+	// Failures must come in a row to open the circuit
+	breakers.record("primary", unavailable)
+	breakers.record("primary", unavailable)
+	breakers.record("primary", &gatewayStatusError{StatusCode: 400})
+	breakers.record("primary", unavailable)
+	breakers.record("primary", unavailable)
+	assert.Equal(t, CircuitClosed, breakers.status("primary").Circuit)
+	assert.Equal(t, 2, breakers.status("primary").Failures)
+	assert.True(t, breakers.allow("primary"))
+
+	breakers.record("primary", unavailable)
+	status := breakers.status("primary")
+	assert.Equal(t, CircuitOpen, status.Circuit)
+	assert.NotNil(t, status.RetryAt)
+	assert.False(t, breakers.available("primary"))
+	assert.False(t, breakers.allow("primary"))
+	assert.Greater(t, breakers.retryAfter("primary"), time.Duration(0))
+	assert.True(t, breakers.available("backup"))
+
+	// Once the wait is over a single probe goes through
+	breakers.mu.Lock()
+	breakers.breakers["primary"].retryAt = time.Now()
+	breakers.mu.Unlock()
+	assert.True(t, breakers.available("primary"))
+	assert.True(t, breakers.allow("primary"))
+	assert.Equal(t, CircuitHalfOpen, breakers.status("primary").Circuit)
+	assert.False(t, breakers.allow("primary"))
+
+	// A failed probe opens the circuit again
+	breakers.record("primary", unavailable)
+	assert.Equal(t, CircuitOpen, breakers.status("primary").Circuit)
+
+	// Enough successful probes in a row close it
+	breakers.mu.Lock()
+	breakers.breakers["primary"].retryAt = time.Now()
+	breakers.mu.Unlock()
+	assert.True(t, breakers.allow("primary"))
+	breakers.record("primary", nil)
+	assert.Equal(t, CircuitHalfOpen, breakers.status("primary").Circuit)
+	assert.True(t, breakers.allow("primary"))
+	breakers.record("primary", &gatewayStatusError{StatusCode: 422})
+	status = breakers.status("primary")
+	assert.Equal(t, CircuitClosed, status.Circuit)
+	assert.Nil(t, status.RetryAt)
+	assert.True(t, breakers.allow("primary"))
+}
+
+func TestTripsBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Success", err: nil, want: false},
+		{name: "Rate limited", err: &gatewayStatusError{StatusCode: 429}, want: true},
+		{name: "Server error", err: &gatewayStatusError{StatusCode: 500}, want: true},
+		{name: "Client error", err: &gatewayStatusError{StatusCode: 400}, want: false},
+		{name: "Timeout", err: fmt.Errorf("error sending request: %w", context.DeadlineExceeded), want: true},
+		{name: "Connection reset", err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: true},
+		{name: "Cancelled", err: fmt.Errorf("error sending request: %w", context.Canceled), want: false},
+		{name: "Internal error", err: errors.New("error marshaling JSON"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tripsBreaker(tt.err))
+		})
+	}
+}
+
+func TestSendMessageWithRetryCircuitOpen(t *testing.T) {
+	t.Setenv("PROVIDERS_FILE", "")
+	useBreakers(t, breakerSettings{failures: 1, openFor: time.Minute, probes: 1})
+	route := defaultRoute()
+	breakers.record(route.gateway.Name, &gatewayStatusError{StatusCode: 503})
+
+	err := NewMessageService().sendMessageWithRetry(context.Background(), &models.Message{ID: 1, To: "+905551234567", Content: "Hi"}, route)
+	var open *CircuitOpenError
+	if assert.ErrorAs(t, err, &open) {
+		assert.Equal(t, route.gateway.Name, open.Provider)
+		assert.Greater(t, open.RetryAfter, 50*time.Second)
+	}
+}
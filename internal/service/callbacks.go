@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+var (
+	// ErrUnknownProvider is returned for callbacks from an unconfigured provider
+	ErrUnknownProvider = errors.New("unknown provider")
+	// ErrInvalidSignature is returned when a callback signature does not verify
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidReceipt is returned when a delivery receipt cannot be parsed
+	ErrInvalidReceipt = errors.New("invalid delivery receipt")
+)
+
+// receiptStatuses maps gateway status codes, including the common SMPP
+// short forms, onto delivery statuses
+var receiptStatuses = map[string]string{
+	"delivered":     models.DeliveryStatusDelivered,
+	"delivrd":       models.DeliveryStatusDelivered,
+	"undeliverable": models.DeliveryStatusUndeliverable,
+	"undeliv":       models.DeliveryStatusUndeliverable,
+	"rejected":      models.DeliveryStatusUndeliverable,
+	"rejectd":       models.DeliveryStatusUndeliverable,
+	"failed":        models.DeliveryStatusUndeliverable,
+	"expired":       models.DeliveryStatusExpired,
+}
+
+var deliveryEvents = map[string]string{
+	models.DeliveryStatusDelivered:     models.EventMessageDelivered,
+	models.DeliveryStatusUndeliverable: models.EventMessageUndeliverable,
+	models.DeliveryStatusExpired:       models.EventMessageExpired,
+}
+
+// deliveryReceiptPayload is the body gateways POST to the DLR endpoint
+type deliveryReceiptPayload struct {
+	MessageID   string     `json:"message_id"`
+	Status      string     `json:"status"`
+	ErrorCode   string     `json:"error_code,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// CallbackService handles requests that gateways send back to us
+type CallbackService struct {
+	keywords map[string]KeywordHandler
+}
+
+func NewCallbackService() *CallbackService {
+	s := &CallbackService{
+		keywords: make(map[string]KeywordHandler),
+	}
+	s.RegisterKeyword(chainHandlers(
+		suppressHandler(models.SuppressionReasonOptOut),
+		optOutReplyHandler(getEnv("STOP_REPLY_TEXT", "")),
+	), stopKeywords...)
+	s.RegisterKeyword(replyHandler(getEnv("HELP_REPLY_TEXT", "")), helpKeywords...)
+	return s
+}
+
+// verifyCallback looks up the provider and checks the request signature
+func verifyCallback(providerName, timestamp, signature string, body []byte) (*provider.Config, error) {
+	gateway, ok := provider.Get(providerName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+	}
+	signature = strings.TrimPrefix(signature, webhookSignatureVersion)
+	if err := gateway.VerifyInbound(timestamp, signature, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return gateway, nil
+}
+
+// HandleDeliveryReceipt verifies a delivery receipt, stores it for audit and
+// applies the final delivery status to the matching message. A gateway's
+// receipts only match messages sent through it, since IDs from different
+// gateways can collide, or messages sent before the provider was recorded.
+func (s *CallbackService) HandleDeliveryReceipt(ctx context.Context, providerName, timestamp, signature string, body []byte) (*models.DeliveryReceipt, error) {
+	gateway, err := verifyCallback(providerName, timestamp, signature, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload deliveryReceiptPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReceipt, err)
+	}
+	if payload.MessageID == "" {
+		return nil, fmt.Errorf("%w: message_id is required", ErrInvalidReceipt)
+	}
+	status, ok := receiptStatuses[strings.ToLower(payload.Status)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidReceipt, payload.Status)
+	}
+
+	receipt := &models.DeliveryReceipt{
+		Provider:          gateway.Name,
+		ProviderMessageID: payload.MessageID,
+		Status:            status,
+		RawPayload:        string(body),
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msg models.Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("message_id = ?", payload.MessageID).
+			Where("(provider = ? OR provider IS NULL OR provider = '')", gateway.Name).
+			// A message sent through this gateway wins over one sent before
+			// providers were recorded
+			Order("provider IS NULL OR provider = ''").
+			First(&msg).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "Delivery receipt for unknown message",
+				"provider", gateway.Name, "provider_message_id", payload.MessageID)
+			return tx.Create(receipt).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		receipt.MessageID = &msg.ID
+		if err := tx.Create(receipt).Error; err != nil {
+			return err
+		}
+
+		// Gateways resend receipts; only the first one changes the message
+		if msg.DeliveryStatus == status {
+			return nil
+		}
+
+		msg.DeliveryStatus = status
+		if status == models.DeliveryStatusDelivered {
+			deliveredAt := time.Now()
+			if payload.DeliveredAt != nil {
+				deliveredAt = *payload.DeliveredAt
+			}
+			msg.DeliveredAt = &deliveredAt
+		}
+		if err := tx.Save(&msg).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, &msg, deliveryEvents[status])
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error storing delivery receipt: %v", err)
+	}
+
+	return receipt, nil
+}
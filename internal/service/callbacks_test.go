@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/signing"
+)
+
+const testInboundSecret = "inbound-secret"
+
+func signedCallback(body string) (string, string) {
+	timestamp := time.Now().Unix()
+	return strconv.FormatInt(timestamp, 10), signing.Sign(testInboundSecret, timestamp, []byte(body))
+}
+
+func TestHandleDeliveryReceiptValidation(t *testing.T) {
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	service := NewCallbackService()
+
+	tests := []struct {
+		name     string
+		provider string
+		body     string
+		badSig   bool
+		wantErr  error
+	}{
+		{
+			name:     "Unknown provider",
+			provider: "other",
+			body:     `{"message_id":"abc","status":"delivered"}`,
+			wantErr:  ErrUnknownProvider,
+		},
+		{
+			name:     "Bad signature",
+			provider: "default",
+			body:     `{"message_id":"abc","status":"delivered"}`,
+			badSig:   true,
+			wantErr:  ErrInvalidSignature,
+		},
+		{
+			name:     "Malformed body",
+			provider: "default",
+			body:     `not json`,
+			wantErr:  ErrInvalidReceipt,
+		},
+		{
+			name:     "Missing message id",
+			provider: "default",
+			body:     `{"status":"delivered"}`,
+			wantErr:  ErrInvalidReceipt,
+		},
+		{
+			name:     "Unknown status",
+			provider: "default",
+			body:     `{"message_id":"abc","status":"queued"}`,
+			wantErr:  ErrInvalidReceipt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp, signature := signedCallback(tt.body)
+			if tt.badSig {
+				signature = signing.Sign("wrong-secret", time.Now().Unix(), []byte(tt.body))
+			}
+
+			_, err := service.HandleDeliveryReceipt(context.Background(), tt.provider, timestamp, signature, []byte(tt.body))
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandleDeliveryReceipt(t *testing.T) {
+	setupTest(t)
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	service := NewCallbackService()
+
+	msg := &models.Message{
+		To:        "+905551234567",
+		Content:   "Test message",
+		Status:    models.StatusSent,
+		Sent:      true,
+		MessageID: "dlr-test-message-id",
+		Provider:  "default",
+	}
+	err := database.DB.Create(msg).Error
+	assert.NoError(t, err)
+	// Another gateway handed out the same ID
+	other := &models.Message{
+		To:        "+905551234568",
+		Content:   "Test message",
+		Status:    models.StatusSent,
+		Sent:      true,
+		MessageID: "dlr-test-message-id",
+		Provider:  "other",
+	}
+	assert.NoError(t, database.DB.Create(other).Error)
+
+	body := `{"message_id":"dlr-test-message-id","status":"DELIVRD"}`
+	timestamp, signature := signedCallback(body)
+
+	receipt, err := service.HandleDeliveryReceipt(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeliveryStatusDelivered, receipt.Status)
+	assert.Equal(t, msg.ID, *receipt.MessageID)
+
+	var updated models.Message
+	assert.NoError(t, database.DB.First(&updated, msg.ID).Error)
+	assert.Equal(t, models.DeliveryStatusDelivered, updated.DeliveryStatus)
+	assert.NotNil(t, updated.DeliveredAt)
+
+	// The other gateway's message is left alone
+	assert.NoError(t, database.DB.First(&updated, other.ID).Error)
+	assert.Empty(t, updated.DeliveryStatus)
+	assert.Nil(t, updated.DeliveredAt)
+
+	// Messages sent before the provider was recorded still match
+	legacy := &models.Message{
+		To:        "+905551234569",
+		Content:   "Test message",
+		Status:    models.StatusSent,
+		Sent:      true,
+		MessageID: "dlr-legacy-message-id",
+	}
+	assert.NoError(t, database.DB.Create(legacy).Error)
+	body = `{"message_id":"dlr-legacy-message-id","status":"UNDELIV"}`
+	timestamp, signature = signedCallback(body)
+	receipt, err = service.HandleDeliveryReceipt(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+	if assert.NotNil(t, receipt.MessageID) {
+		assert.Equal(t, legacy.ID, *receipt.MessageID)
+	}
+
+	// Clean up
+	database.DB.Where("message_id IN ?", []uint{msg.ID, legacy.ID}).Delete(&models.DeliveryReceipt{})
+	database.DB.Where("aggregate_id IN ?", []uint{msg.ID, legacy.ID}).Delete(&models.OutboxEvent{})
+	database.DB.Unscoped().Delete(msg)
+	database.DB.Unscoped().Delete(other)
+	database.DB.Unscoped().Delete(legacy)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+const (
+	fallbackInterval  = time.Minute
+	fallbackBatchSize = 50
+	maxFallbacks      = 3
+)
+
+// errFallbackStarted is returned when another transaction already created a
+// message's next attempt
+var errFallbackStarted = errors.New("fallback already started")
+
+// fallbackEvents are the events that end an attempt without delivery
+var fallbackEvents = map[string]bool{
+	models.EventMessageDeadLettered:  true,
+	models.EventMessageSuppressed:    true,
+	models.EventMessageUndeliverable: true,
+	models.EventMessageExpired:       true,
+}
+
+// FallbackMonitor starts the next attempt of messages that were sent but
+// got no delivery receipt within their fallback timeout. Attempts that fail
+// permanently fall back as soon as their failure is recorded.
+type FallbackMonitor struct {
+	interval time.Duration
+}
+
+func NewFallbackMonitor() *FallbackMonitor {
+	return &FallbackMonitor{
+		interval: fallbackInterval,
+	}
+}
+
+// Run checks for timed out attempts until ctx is cancelled
+func (m *FallbackMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error checking message fallbacks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *FallbackMonitor) check(ctx context.Context) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var messages []models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND fallback_timeout > 0 AND fallback_id IS NULL", models.StatusSent).
+			Where("COALESCE(delivery_status, '') = ''").
+			Where("sent_at + fallback_timeout * INTERVAL '1 second' <= ?", time.Now()).
+			Order("sent_at").
+			Limit(fallbackBatchSize).
+			Find(&messages).Error; err != nil {
+			return fmt.Errorf("error fetching messages awaiting fallback: %v", err)
+		}
+
+		for i := range messages {
+			if err := startFallback(tx, &messages[i], metrics.FallbackTimeout); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fallbackTimeout returns how long a sent attempt waits for a delivery
+// receipt before falling back, from FALLBACK_TIMEOUT
+func fallbackTimeout() (time.Duration, error) {
+	value := getEnv("FALLBACK_TIMEOUT", "15m")
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < time.Second {
+		return 0, fmt.Errorf("invalid FALLBACK_TIMEOUT %q", value)
+	}
+	return timeout, nil
+}
+
+// prepareFallbacks validates a new message's fallback chain with each
+// channel's sender, normalizing the recipients, and sets its timeout. A
+// FallbackTimeout of 0 uses FALLBACK_TIMEOUT.
+func prepareFallbacks(msg *models.Message) error {
+	if len(msg.Fallbacks) == 0 {
+		if msg.FallbackTimeout != 0 {
+			return fmt.Errorf("%w: fallback_timeout needs fallbacks", ErrInvalidMessage)
+		}
+		return nil
+	}
+	if len(msg.Fallbacks) > maxFallbacks {
+		return fmt.Errorf("%w: at most %d fallbacks are allowed", ErrInvalidMessage, maxFallbacks)
+	}
+	if msg.FallbackTimeout < 0 {
+		return fmt.Errorf("%w: fallback_timeout cannot be negative", ErrInvalidMessage)
+	}
+	if msg.FallbackTimeout == 0 {
+		timeout, err := fallbackTimeout()
+		if err != nil {
+			return err
+		}
+		msg.FallbackTimeout = int(timeout.Seconds())
+	}
+
+	previous := msg
+	for i := range msg.Fallbacks {
+		next := fallbackMessage(previous, msg.Fallbacks[i:])
+		channelSender, err := senderFor(next.Channel)
+		if err != nil {
+			return fmt.Errorf("%w: fallback %d: %v", ErrInvalidMessage, i+1, err)
+		}
+		if _, ok := provider.ForChannel(next.Channel); !ok {
+			return fmt.Errorf("%w: fallback %d: no provider is configured for %s messages", ErrInvalidMessage, i+1, next.Channel)
+		}
+		if err := channelSender.validate(next); err != nil {
+			return fmt.Errorf("fallback %d: %w", i+1, err)
+		}
+		msg.Fallbacks[i].To = next.To
+		previous = next
+	}
+	return nil
+}
+
+// fallbackMessage builds the attempt that follows msg from the first step
+// of chain, which holds the steps still to come
+func fallbackMessage(msg *models.Message, chain []models.Fallback) *models.Message {
+	step := chain[0]
+	next := &models.Message{
+		TenantID:    msg.TenantID,
+		Channel:     step.Channel,
+		To:          step.To,
+		Content:     step.Content,
+		Subject:     step.Subject,
+		HTMLBody:    step.HTMLBody,
+		Title:       step.Title,
+		CallbackURL: msg.CallbackURL,
+		OriginalID:  msg.OriginalID,
+		TraceParent: msg.TraceParent,
+	}
+	if next.Content == "" {
+		next.Content = msg.Content
+		next.TemplateID = msg.TemplateID
+	}
+	if next.OriginalID == nil && msg.ID != 0 {
+		next.OriginalID = &msg.ID
+	}
+	if len(chain) > 1 {
+		next.Fallbacks = chain[1:]
+		next.FallbackTimeout = msg.FallbackTimeout
+	}
+	return next
+}
+
+// startFallback creates the next attempt of a message that was not
+// delivered, using the caller's transaction. It does nothing if the message
+// has no fallback left or its next attempt already exists.
+func startFallback(tx *gorm.DB, msg *models.Message, reason string) error {
+	if len(msg.Fallbacks) == 0 || msg.FallbackID != nil {
+		return nil
+	}
+
+	next := fallbackMessage(msg, msg.Fallbacks)
+	next.Status = models.StatusPending
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := insertMessage(tx, next); err != nil {
+			return err
+		}
+		result := tx.Exec("UPDATE messages SET fallback_id = ? WHERE id = ? AND fallback_id IS NULL", next.ID, msg.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errFallbackStarted
+		}
+		msg.FallbackID = &next.ID
+		return recordStatusChange(tx, msg, models.EventMessageFallback)
+	})
+	if errors.Is(err, errFallbackStarted) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error starting fallback for message %d: %v", msg.ID, err)
+	}
+
+	metrics.FallbacksStarted.WithLabelValues(msg.Channel, next.Channel, reason).Inc()
+	messageLogger(msg).InfoContext(tx.Statement.Context, "Message fell back to next channel",
+		"reason", reason, "fallback_message_id", next.ID, "fallback_channel", next.Channel)
+	return nil
+}
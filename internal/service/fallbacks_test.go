@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+func TestFallbackTimeout(t *testing.T) {
+	t.Setenv("FALLBACK_TIMEOUT", "")
+	_, err := fallbackTimeout()
+	assert.Error(t, err)
+
+	t.Setenv("FALLBACK_TIMEOUT", "10m")
+	timeout, err := fallbackTimeout()
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, timeout)
+
+	t.Setenv("FALLBACK_TIMEOUT", "10ms")
+	_, err = fallbackTimeout()
+	assert.Error(t, err)
+}
+
+func TestPrepareFallbacks(t *testing.T) {
+	t.Setenv("PROVIDERS_FILE", "")
+	t.Setenv("PHONE_DEFAULT_REGION", "")
+	t.Setenv("FALLBACK_TIMEOUT", "15m")
+
+	tests := []struct {
+		name        string
+		msg         models.Message
+		wantTimeout int
+		wantTo      []string
+		wantErr     bool
+	}{
+		{
+			name:        "No fallbacks",
+			msg:         models.Message{Content: "Hi"},
+			wantTimeout: 0,
+		},
+		{
+			name: "Default timeout",
+			msg: models.Message{Content: "Hi", Fallbacks: []models.Fallback{
+				{Channel: models.ChannelSMS, To: "+90 555 123 45 68"},
+			}},
+			wantTimeout: 900,
+			wantTo:      []string{"+905551234568"},
+		},
+		{
+			name: "Given timeout",
+			msg: models.Message{Content: "Hi", FallbackTimeout: 60, Fallbacks: []models.Fallback{
+				{Channel: models.ChannelSMS, To: "+905551234568"},
+				{Channel: models.ChannelSMS, To: "+905551234569", Content: "Still there?"},
+			}},
+			wantTimeout: 60,
+			wantTo:      []string{"+905551234568", "+905551234569"},
+		},
+		{
+			name:    "Timeout without fallbacks",
+			msg:     models.Message{Content: "Hi", FallbackTimeout: 60},
+			wantErr: true,
+		},
+		{
+			name: "Invalid fallback recipient",
+			msg: models.Message{Content: "Hi", Fallbacks: []models.Fallback{
+				{Channel: models.ChannelSMS, To: "12345"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Channel without provider",
+			msg: models.Message{Content: "Hi", Fallbacks: []models.Fallback{
+				{Channel: models.ChannelEmail, To: "jane@example.com", Subject: "Hi"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "Too many fallbacks",
+			msg: models.Message{Content: "Hi", Fallbacks: []models.Fallback{
+				{Channel: models.ChannelSMS, To: "+905551234561"},
+				{Channel: models.ChannelSMS, To: "+905551234562"},
+				{Channel: models.ChannelSMS, To: "+905551234563"},
+				{Channel: models.ChannelSMS, To: "+905551234564"},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			err := prepareFallbacks(&msg)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidMessage))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTimeout, msg.FallbackTimeout)
+			for i, to := range tt.wantTo {
+				assert.Equal(t, to, msg.Fallbacks[i].To)
+			}
+		})
+	}
+}
+
+func TestFallbackMessage(t *testing.T) {
+	templateID := uint(7)
+	first := &models.Message{
+		ID:              10,
+		TenantID:        2,
+		Channel:         models.ChannelPush,
+		To:              "device-token",
+		Title:           "Your code",
+		Content:         "Code: 1234",
+		CallbackURL:     "https://client.example.com/hook",
+		TemplateID:      &templateID,
+		FallbackTimeout: 300,
+		Fallbacks: []models.Fallback{
+			{Channel: models.ChannelSMS, To: "+905551234567"},
+			{Channel: models.ChannelEmail, To: "jane@example.com", Subject: "Your code", Content: "Your code is 1234"},
+		},
+	}
+
+	second := fallbackMessage(first, first.Fallbacks)
+	assert.Equal(t, uint(2), second.TenantID)
+	assert.Equal(t, models.ChannelSMS, second.Channel)
+	assert.Equal(t, "+905551234567", second.To)
+	assert.Equal(t, "Code: 1234", second.Content)
+	assert.Empty(t, second.Title)
+	assert.Equal(t, &templateID, second.TemplateID)
+	assert.Equal(t, "https://client.example.com/hook", second.CallbackURL)
+	assert.Equal(t, uint(10), *second.OriginalID)
+	assert.Equal(t, first.Fallbacks[1:], second.Fallbacks)
+	assert.Equal(t, 300, second.FallbackTimeout)
+
+	second.ID = 11
+	third := fallbackMessage(second, second.Fallbacks)
+	assert.Equal(t, models.ChannelEmail, third.Channel)
+	assert.Equal(t, "Your code is 1234", third.Content)
+	assert.Nil(t, third.TemplateID)
+	assert.Equal(t, uint(10), *third.OriginalID)
+	assert.Empty(t, third.Fallbacks)
+	assert.Zero(t, third.FallbackTimeout)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// Overall readiness states
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// healthCheckTimeout bounds each dependency ping so a hung dependency cannot
+// hang the probe
+const healthCheckTimeout = 2 * time.Second
+
+// DependencyHealth is the result of pinging one dependency
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ProviderHealth is the state of a provider's circuit breaker in this
+// replica. Failures counts failed sends in a row while the circuit is
+// closed; RetryAt is when an open circuit lets its next probe through.
+type ProviderHealth struct {
+	Channel  string     `json:"channel"`
+	Circuit  string     `json:"circuit"`
+	Failures int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// HealthReport describes whether the service can do useful work
+type HealthReport struct {
+	Status       string                      `json:"status"`
+	Processing   bool                        `json:"processing"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	Providers    map[string]ProviderHealth   `json:"providers"`
+}
+
+// Ready reports whether every required dependency is up
+func (r *HealthReport) Ready() bool {
+	return r.Status != HealthDown
+}
+
+type HealthService struct {
+	messageService *MessageService
+}
+
+func NewHealthService(messageService *MessageService) *HealthService {
+	return &HealthService{messageService: messageService}
+}
+
+// Check pings the database and Redis and reports the providers' circuit
+// breakers. The database is required; Redis only provides caching and rate
+// limiting, so losing it degrades the service without making it unready.
+// A provider whose circuit is not closed degrades it too, since its
+// messages wait or go through other providers.
+func (s *HealthService) Check(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:     HealthOK,
+		Processing: s.messageService.IsProcessing(),
+		Dependencies: map[string]DependencyHealth{
+			"database": checkDependency(ctx, true, pingDatabase),
+			"redis":    checkDependency(ctx, false, pingRedis),
+		},
+		Providers: make(map[string]ProviderHealth),
+	}
+	for _, cfg := range provider.All() {
+		status := breakers.status(cfg.Name)
+		status.Channel = cfg.Channel
+		if status.Channel == "" {
+			status.Channel = models.ChannelSMS
+		}
+		report.Providers[cfg.Name] = status
+		if status.Circuit != CircuitClosed {
+			report.Status = HealthDegraded
+		}
+	}
+
+	for _, dep := range report.Dependencies {
+		if dep.Status == HealthOK {
+			continue
+		}
+		if dep.Required {
+			report.Status = HealthDown
+			break
+		}
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+func checkDependency(ctx context.Context, required bool, ping func(context.Context) error) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	dep := DependencyHealth{
+		Status:    HealthOK,
+		Required:  required,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		dep.Status = HealthDown
+		dep.Error = err.Error()
+	}
+	return dep
+}
+
+func pingDatabase(ctx context.Context) error {
+	if database.DB == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func pingRedis(ctx context.Context) error {
+	if redis.Client == nil {
+		return errors.New("redis not initialized")
+	}
+	return redis.Client.Ping(ctx).Err()
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func TestHealthCheckDatabaseDown(t *testing.T) {
+	setupLocalRedis(t)
+	previous := database.DB
+	database.DB = nil
+	defer func() { database.DB = previous }()
+
+	report := NewHealthService(NewMessageService()).Check(context.Background())
+	assert.Equal(t, HealthDown, report.Status)
+	assert.False(t, report.Ready())
+	assert.Equal(t, HealthDown, report.Dependencies["database"].Status)
+	assert.NotEmpty(t, report.Dependencies["database"].Error)
+	assert.Equal(t, HealthOK, report.Dependencies["redis"].Status)
+	assert.False(t, report.Processing)
+	assert.Equal(t, CircuitClosed, report.Providers[provider.Default().Name].Circuit)
+}
+
+func TestHealthCheckRedisDown(t *testing.T) {
+	setupTest(t)
+	mr := setupLocalRedis(t)
+	mr.Close()
+
+	report := NewHealthService(NewMessageService()).Check(context.Background())
+	assert.Equal(t, HealthDegraded, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, HealthOK, report.Dependencies["database"].Status)
+	assert.Equal(t, HealthDown, report.Dependencies["redis"].Status)
+	assert.False(t, report.Dependencies["redis"].Required)
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+const (
+	// idempotencyTTL is how long a response is kept for replay
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyClaimTTL bounds how long a key stays locked by a request
+	// that never finished, e.g. because the replica crashed
+	idempotencyClaimTTL     = time.Minute
+	maxIdempotencyKeyLength = 255
+)
+
+// ErrInvalidIdempotencyKey is returned when an idempotency key is malformed
+var ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// ErrIdempotencyKeyReused is returned when a key is sent again with a
+// different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// ErrIdempotencyKeyInProgress is returned while the first request sent with
+// a key is still being processed
+var ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+
+// IdempotencyService remembers responses to requests sent with an
+// Idempotency-Key. Records live in Redis; while Redis is unavailable they
+// are kept in Postgres instead, which is also checked on every lookup.
+type IdempotencyService struct{}
+
+func NewIdempotencyService() *IdempotencyService {
+	return &IdempotencyService{}
+}
+
+// Begin starts a request sent with a tenant's key. If the same request was
+// already answered, its record is returned for replay. Otherwise the key is
+// claimed, nil is returned, and the caller must Complete or Release it.
+func (s *IdempotencyService) Begin(ctx context.Context, tenantID uint, key, requestHash string) (*models.IdempotencyRecord, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+
+	existing, err := s.lookup(ctx, tenantID, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return checkIdempotencyRecord(existing, requestHash)
+	}
+
+	claimed, err := s.claim(ctx, &models.IdempotencyRecord{
+		TenantID:    tenantID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(idempotencyClaimTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	// Another request claimed the key since the lookup
+	existing, err = s.lookup(ctx, tenantID, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return checkIdempotencyRecord(existing, requestHash)
+}
+
+// Complete stores the response to a claimed request for replay
+func (s *IdempotencyService) Complete(ctx context.Context, tenantID uint, key, requestHash string, statusCode int, body []byte) error {
+	record := &models.IdempotencyRecord{
+		TenantID:     tenantID,
+		Key:          key,
+		RequestHash:  requestHash,
+		Completed:    true,
+		StatusCode:   statusCode,
+		ResponseBody: string(body),
+		ExpiresAt:    time.Now().Add(idempotencyTTL),
+	}
+
+	err := redis.SetIdempotencyRecord(ctx, record, idempotencyTTL)
+	if err == nil {
+		return nil
+	}
+	slog.WarnContext(ctx, "Storing idempotency record in Postgres", "error", err)
+
+	err = database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "completed", "status_code", "response_body", "expires_at", "updated_at"}),
+	}).Create(record).Error
+	if err != nil {
+		return fmt.Errorf("error storing idempotency record: %v", err)
+	}
+	// Drop the claim if Redis is back, so retries find the stored response
+	if err := redis.DeleteIdempotencyRecord(ctx, tenantID, key); err != nil {
+		slog.WarnContext(ctx, "Failed to release idempotency key in Redis", "error", err)
+	}
+	return nil
+}
+
+// Release drops the claim on a key whose request produced nothing worth
+// replaying, so a retry is processed afresh
+func (s *IdempotencyService) Release(ctx context.Context, tenantID uint, key string) error {
+	if err := redis.DeleteIdempotencyRecord(ctx, tenantID, key); err != nil {
+		slog.WarnContext(ctx, "Failed to release idempotency key in Redis", "error", err)
+	}
+	if err := database.DB.WithContext(ctx).
+		Where("tenant_id = ? AND key = ? AND completed = ?", tenantID, key, false).
+		Delete(&models.IdempotencyRecord{}).Error; err != nil {
+		return fmt.Errorf("error releasing idempotency key: %v", err)
+	}
+	return nil
+}
+
+// lookup returns the live record for a key from Redis, or from Postgres if
+// Redis has none, is unavailable or only holds a claim. A claim can outlive
+// its request when Redis failed as the response was stored in Postgres.
+func (s *IdempotencyService) lookup(ctx context.Context, tenantID uint, key string) (*models.IdempotencyRecord, error) {
+	record, err := redis.GetIdempotencyRecord(ctx, tenantID, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Idempotency lookup in Redis failed", "error", err)
+	}
+	if record != nil && record.Completed {
+		return record, nil
+	}
+
+	var stored models.IdempotencyRecord
+	err = database.DB.WithContext(ctx).
+		Where("tenant_id = ? AND key = ? AND expires_at > ?", tenantID, key, time.Now()).
+		First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return record, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up idempotency key: %v", err)
+	}
+	if record != nil && !stored.Completed {
+		return record, nil
+	}
+	return &stored, nil
+}
+
+// claim stores record unless its key has a live record, in Redis or, while
+// Redis is unavailable, in Postgres where expired records are taken over
+func (s *IdempotencyService) claim(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	claimed, err := redis.ClaimIdempotencyKey(ctx, record, idempotencyClaimTTL)
+	if err == nil {
+		return claimed, nil
+	}
+	slog.WarnContext(ctx, "Claiming idempotency key in Postgres", "error", err)
+
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "key"}},
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Lte{Column: clause.Column{Table: "idempotency_records", Name: "expires_at"}, Value: time.Now()}}},
+		DoUpdates: clause.AssignmentColumns([]string{"request_hash", "completed", "status_code", "response_body", "expires_at", "updated_at"}),
+	}).Create(record)
+	if result.Error != nil {
+		return false, fmt.Errorf("error claiming idempotency key: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func checkIdempotencyRecord(record *models.IdempotencyRecord, requestHash string) (*models.IdempotencyRecord, error) {
+	if record.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !record.Completed {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return record, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func TestIdempotencyReplaysResponseStoredWhileRedisWasDown(t *testing.T) {
+	setupTest(t)
+	mr := setupLocalRedis(t)
+	service := NewIdempotencyService()
+	ctx := context.Background()
+
+	key := "idempotency-redis-outage"
+	t.Cleanup(func() {
+		database.DB.Where("tenant_id = ? AND key = ?", models.DefaultTenantID, key).Delete(&models.IdempotencyRecord{})
+	})
+
+	record, err := service.Begin(ctx, models.DefaultTenantID, key, "hash")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	// The claim is left in Redis and the response goes to Postgres
+	mr.SetError("connection lost")
+	require.NoError(t, service.Complete(ctx, models.DefaultTenantID, key, "hash", http.StatusCreated, []byte(`{"id":1}`)))
+	mr.SetError("")
+
+	record, err = service.Begin(ctx, models.DefaultTenantID, key, "hash")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, http.StatusCreated, record.StatusCode)
+	assert.Equal(t, `{"id":1}`, record.ResponseBody)
+}
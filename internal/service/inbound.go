@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+// ErrInvalidInboundMessage is returned when an inbound message cannot be parsed
+var ErrInvalidInboundMessage = errors.New("invalid inbound message")
+
+var (
+	stopKeywords = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	helpKeywords = []string{"HELP", "INFO"}
+)
+
+// KeywordHandler runs when an inbound message starts with a registered
+// keyword. It runs in the transaction that stores the inbound message, so an
+// error rejects the whole callback and the gateway retries it.
+type KeywordHandler func(tx *gorm.DB, inbound *models.InboundMessage) error
+
+// inboundMessagePayload is the body gateways POST to the inbound endpoint
+type inboundMessagePayload struct {
+	MessageID  string     `json:"message_id"`
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Content    string     `json:"content"`
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+}
+
+// RegisterKeyword installs a handler for one or more keywords, replacing any
+// existing handler for them
+func (s *CallbackService) RegisterKeyword(handler KeywordHandler, keywords ...string) {
+	for _, keyword := range keywords {
+		s.keywords[strings.ToUpper(keyword)] = handler
+	}
+}
+
+// chainHandlers runs several handlers in order, stopping at the first error
+func chainHandlers(handlers ...KeywordHandler) KeywordHandler {
+	return func(tx *gorm.DB, inbound *models.InboundMessage) error {
+		for _, handler := range handlers {
+			if err := handler(tx, inbound); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// replyHandler answers a keyword with a fixed message. An empty reply
+// disables the answer.
+func replyHandler(reply string) KeywordHandler {
+	return func(tx *gorm.DB, inbound *models.InboundMessage) error {
+		if reply == "" {
+			return nil
+		}
+		return insertMessage(tx, &models.Message{
+			TenantID: inbound.TenantID,
+			To:       inbound.From,
+			Content:  reply,
+			Status:   models.StatusPending,
+		})
+	}
+}
+
+// optOutReplyHandler confirms an opt-out with a fixed message. The
+// confirmation is exempt from the suppression the opt-out just created. An
+// empty reply disables the answer.
+func optOutReplyHandler(reply string) KeywordHandler {
+	return func(tx *gorm.DB, inbound *models.InboundMessage) error {
+		if reply == "" {
+			return nil
+		}
+		return insertMessage(tx, &models.Message{
+			TenantID:           inbound.TenantID,
+			To:                 inbound.From,
+			Content:            reply,
+			Status:             models.StatusPending,
+			OptOutConfirmation: true,
+		})
+	}
+}
+
+// parseKeyword returns the first word of a message, upper-cased and without
+// trailing punctuation
+func parseKeyword(content string) string {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.Trim(fields[0], ".,!?;:"))
+}
+
+// HandleInboundMessage verifies and stores a reply, links it to the latest
+// message we sent to that number, runs keyword handlers and notifies clients.
+// The reply belongs to that message's tenant, or to the default tenant when
+// there is none.
+func (s *CallbackService) HandleInboundMessage(ctx context.Context, providerName, timestamp, signature string, body []byte) (*models.InboundMessage, error) {
+	gateway, err := verifyCallback(providerName, timestamp, signature, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload inboundMessagePayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInboundMessage, err)
+	}
+	if payload.From == "" {
+		return nil, fmt.Errorf("%w: from is required", ErrInvalidInboundMessage)
+	}
+
+	inbound := &models.InboundMessage{
+		TenantID:          models.DefaultTenantID,
+		Provider:          gateway.Name,
+		ProviderMessageID: payload.MessageID,
+		From:              recipientKey(payload.From),
+		To:                payload.To,
+		Content:           payload.Content,
+		ReceivedAt:        time.Now(),
+	}
+	if payload.ReceivedAt != nil {
+		inbound.ReceivedAt = *payload.ReceivedAt
+	}
+
+	keyword := parseKeyword(payload.Content)
+	handler, hasHandler := s.keywords[keyword]
+	if hasHandler {
+		inbound.Keyword = keyword
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Gateways retry callbacks; a known message ID has already been handled
+		if payload.MessageID != "" {
+			var existing models.InboundMessage
+			err := tx.Where("provider = ? AND provider_message_id = ?", gateway.Name, payload.MessageID).
+				First(&existing).Error
+			if err == nil {
+				*inbound = existing
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		var related models.Message
+		err := tx.Where(&models.Message{To: inbound.From, Status: models.StatusSent}).
+			Order("sent_at desc").
+			First(&related).Error
+		hasRelated := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if hasRelated {
+			inbound.RelatedMessageID = &related.ID
+			inbound.TenantID = related.TenantID
+		}
+
+		if err := tx.Create(inbound).Error; err != nil {
+			return err
+		}
+
+		if hasHandler {
+			if err := handler(tx, inbound); err != nil {
+				return fmt.Errorf("keyword %s handler failed: %v", keyword, err)
+			}
+		}
+
+		event := messageEventPayload{
+			OccurredAt:     inbound.ReceivedAt,
+			InboundMessage: inbound,
+		}
+		var messageID uint
+		var callbackURL string
+		if hasRelated {
+			event.Message = &related
+			messageID = related.ID
+			callbackURL = related.CallbackURL
+		}
+		if err := recordOutboxEvent(tx, "inbound_message", inbound.ID, models.EventMessageInbound, event); err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, inbound.TenantID, messageID, callbackURL, models.EventMessageInbound, event)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error storing inbound message: %v", err)
+	}
+
+	return inbound, nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+	"gorm.io/gorm"
+)
+
+func TestParseKeyword(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "STOP", want: "STOP"},
+		{content: "  stop please", want: "STOP"},
+		{content: "Help!", want: "HELP"},
+		{content: "", want: ""},
+		{content: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseKeyword(tt.content), "content=%q", tt.content)
+	}
+}
+
+func TestHandleInboundMessageValidation(t *testing.T) {
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	service := NewCallbackService()
+
+	body := `{"message_id":"mo-1","to":"4545","content":"STOP"}`
+	timestamp, signature := signedCallback(body)
+	_, err := service.HandleInboundMessage(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.ErrorIs(t, err, ErrInvalidInboundMessage)
+
+	body = `{"message_id":"mo-1","from":"+905551234567","content":"STOP"}`
+	_, err = service.HandleInboundMessage(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestHandleInboundMessage(t *testing.T) {
+	setupTest(t)
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	service := NewCallbackService()
+
+	var handled []string
+	service.RegisterKeyword(func(tx *gorm.DB, inbound *models.InboundMessage) error {
+		handled = append(handled, inbound.Keyword)
+		return nil
+	}, "STOP")
+
+	outbound := &models.Message{
+		To:        "+905559876543",
+		Content:   "Your code is 1234",
+		Status:    models.StatusSent,
+		Sent:      true,
+		SentAt:    time.Now(),
+		MessageID: "inbound-test-outbound-id",
+	}
+	assert.NoError(t, database.DB.Create(outbound).Error)
+
+	body := `{"message_id":"inbound-test-mo-id","from":"+905559876543","to":"4545","content":"stop"}`
+	timestamp, signature := signedCallback(body)
+
+	inbound, err := service.HandleInboundMessage(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+	assert.Equal(t, "STOP", inbound.Keyword)
+	assert.Equal(t, outbound.ID, *inbound.RelatedMessageID)
+	assert.Equal(t, []string{"STOP"}, handled)
+
+	// A retried callback is not handled twice
+	again, err := service.HandleInboundMessage(context.Background(), "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+	assert.Equal(t, inbound.ID, again.ID)
+	assert.Len(t, handled, 1)
+
+	// Clean up
+	database.DB.Where("aggregate_id = ? AND aggregate_type = ?", inbound.ID, "inbound_message").Delete(&models.OutboxEvent{})
+	database.DB.Delete(inbound)
+	database.DB.Unscoped().Delete(outbound)
+}
+
+func TestStopReplySendsConfirmation(t *testing.T) {
+	setupTest(t)
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	t.Setenv("STOP_REPLY_TEXT", "You are unsubscribed")
+	service := NewCallbackService()
+	ctx := context.Background()
+
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body["content"])
+	}))
+	defer server.Close()
+	route := &sendRoute{gateway: &provider.Config{Name: "test", URL: server.URL}, pinned: true}
+
+	recipient := "+905557654321"
+	body := `{"message_id":"stop-confirmation-mo-id","from":"` + recipient + `","to":"4545","content":"STOP"}`
+	timestamp, signature := signedCallback(body)
+	inbound, err := service.HandleInboundMessage(ctx, "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+
+	var confirmation models.Message
+	assert.NoError(t, database.DB.Where("\"to\" = ? AND opt_out_confirmation = ?", recipient, true).Last(&confirmation).Error)
+	other := &models.Message{To: recipient, Content: "Weekly offers", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(other).Error)
+
+	messages := NewMessageService()
+	messages.processMessage(ctx, &confirmation, route)
+	messages.processMessage(ctx, other, route)
+
+	assert.NoError(t, database.DB.First(&confirmation, confirmation.ID).Error)
+	assert.NoError(t, database.DB.First(other, other.ID).Error)
+	assert.Equal(t, models.StatusSent, confirmation.Status)
+	assert.Equal(t, models.StatusSuppressed, other.Status)
+	assert.Equal(t, []string{"You are unsubscribed"}, sent)
+
+	// Clean up
+	assert.NoError(t, NewSuppressionService().Remove(inbound.TenantID, recipient))
+	database.DB.Where("aggregate_id IN ?", []uint{confirmation.ID, other.ID}).Delete(&models.OutboxEvent{})
+	database.DB.Where("message_id IN ?", []uint{confirmation.ID, other.ID}).Delete(&models.DeliveryAttempt{})
+	database.DB.Where("aggregate_id = ? AND aggregate_type = ?", inbound.ID, "inbound_message").Delete(&models.OutboxEvent{})
+	database.DB.Delete(inbound)
+	database.DB.Unscoped().Delete(&confirmation)
+	database.DB.Unscoped().Delete(other)
+}
+
+func TestStopReplySuppressesEveryTenant(t *testing.T) {
+	setupTest(t)
+	t.Setenv("PROVIDER_INBOUND_SECRET", testInboundSecret)
+	t.Setenv("STOP_REPLY_TEXT", "")
+	service := NewCallbackService()
+	ctx := context.Background()
+
+	recipient := "+905557654323"
+	sentAt := time.Now().Add(-time.Hour)
+	// Two tenants messaged the number through the gateway the STOP comes in
+	// on, a third through another gateway
+	first := &models.Message{TenantID: models.DefaultTenantID, To: recipient, Content: "Offer", Status: models.StatusSent, Sent: true, SentAt: sentAt, Provider: "default"}
+	second := &models.Message{TenantID: 9998, To: recipient, Content: "Offer", Status: models.StatusSent, Sent: true, SentAt: sentAt.Add(time.Minute), Provider: "default"}
+	elsewhere := &models.Message{TenantID: 9997, To: recipient, Content: "Offer", Status: models.StatusSent, Sent: true, SentAt: sentAt, Provider: "other"}
+	for _, msg := range []*models.Message{first, second, elsewhere} {
+		assert.NoError(t, database.DB.Create(msg).Error)
+	}
+
+	body := `{"message_id":"stop-every-tenant-mo-id","from":"` + recipient + `","to":"4545","content":"STOP"}`
+	timestamp, signature := signedCallback(body)
+	inbound, err := service.HandleInboundMessage(ctx, "default", timestamp, signature, []byte(body))
+	assert.NoError(t, err)
+	assert.Equal(t, second.TenantID, inbound.TenantID)
+
+	for _, tt := range []struct {
+		tenantID uint
+		want     bool
+	}{
+		{tenantID: first.TenantID, want: true},
+		{tenantID: second.TenantID, want: true},
+		{tenantID: elsewhere.TenantID, want: false},
+	} {
+		suppressed, err := isSuppressed(ctx, tt.tenantID, recipient)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, suppressed, "tenant %d", tt.tenantID)
+	}
+
+	// Clean up
+	database.DB.Where("recipient = ?", recipient).Delete(&models.Suppression{})
+	for _, tenantID := range []uint{first.TenantID, second.TenantID, elsewhere.TenantID} {
+		redis.ClearSuppression(ctx, tenantID, recipient)
+	}
+	database.DB.Where("aggregate_id = ? AND aggregate_type = ?", inbound.ID, "inbound_message").Delete(&models.OutboxEvent{})
+	database.DB.Delete(inbound)
+	database.DB.Unscoped().Delete(first)
+	database.DB.Unscoped().Delete(second)
+	database.DB.Unscoped().Delete(elsewhere)
+}
@@ -1,51 +1,116 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/logging"
+	"github.com/vkukul/messaging-system/internal/metrics"
 	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/internal/tracing"
 	"github.com/vkukul/messaging-system/pkg/database"
 	"github.com/vkukul/messaging-system/pkg/redis"
+	"github.com/vkukul/messaging-system/pkg/sms"
 )
 
 const (
-	webhookURL      = "https://httpbin.org/post"
 	batchSize       = 2
 	processInterval = 2 * time.Minute
 	maxWorkers      = 5
 	maxRetries      = 3
+	// maxSendAttempts is how many processing rounds a message may fail
+	// before it is dead-lettered
+	maxSendAttempts = 5
+	// invalidTenantRetryDelay is how long the messages of a tenant with
+	// invalid settings wait before they are picked up again
+	invalidTenantRetryDelay = 15 * time.Minute
 )
 
+// ErrInvalidMessage is returned when a message fails validation
+var ErrInvalidMessage = errors.New("invalid message")
+
+// ErrMessageNotFound is returned when a message does not exist
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrSendUnrecorded is returned when the gateway accepted a message but the
+// send could not be recorded. The message must not be sent again; it stays
+// claimed until the reconciler resolves it.
+var ErrSendUnrecorded = errors.New("message was sent but could not be recorded")
+
+// errMessageClaimed is returned when a message is no longer pending or
+// failed, e.g. because another replica is already sending it
+var errMessageClaimed = errors.New("message is already being sent")
+
+// RateLimitedError is returned when a recipient's rate limit denies a send.
+// It is not a failure: the message is deferred without using up an attempt.
+type RateLimitedError struct {
+	Recipient  string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for recipient %s, retry in %v", e.Recipient, e.RetryAfter)
+}
+
+// sendBudget records the limits a message has been counted against in a
+// processing round, so that retries and failovers do not count it again
+type sendBudget struct {
+	recipient bool
+	// providers holds the gateways whose throughput the message has used
+	providers map[string]bool
+}
+
+func newSendBudget() *sendBudget {
+	return &sendBudget{providers: make(map[string]bool)}
+}
+
 type MessageService struct {
 	processing bool
 	client     *http.Client
 	mu         sync.RWMutex
 	workers    chan struct{}
+	// nextTenant is where the next batch starts its tenant rotation
+	nextTenant uint
 }
 
 func NewMessageService() *MessageService {
 	return &MessageService{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+		client:  newHTTPClient(),
 		workers: make(chan struct{}, maxWorkers),
 	}
 }
 
+// newHTTPClient returns the pooled client used for all outbound webhooks.
+// Requests are traced and carry a traceparent header.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: otelhttp.NewTransport(&http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		}),
+	}
+}
+
 func (s *MessageService) StartProcessing() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -55,6 +120,7 @@ func (s *MessageService) StartProcessing() error {
 	}
 
 	s.processing = true
+	metrics.ProcessorRunning.Set(1)
 	go s.processMessages()
 	return nil
 }
@@ -63,9 +129,11 @@ func (s *MessageService) StopProcessing() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.processing = false
+	metrics.ProcessorRunning.Set(0)
 }
 
-func (s *MessageService) isProcessing() bool {
+// IsProcessing reports whether automatic sending is running
+func (s *MessageService) IsProcessing() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.processing
@@ -75,10 +143,19 @@ func (s *MessageService) processMessages() {
 	ticker := time.NewTicker(processInterval)
 	defer ticker.Stop()
 
-	for s.isProcessing() {
-		var messages []models.Message
-		if err := database.DB.Where("sent = ?", false).Limit(batchSize).Find(&messages).Error; err != nil {
-			log.Printf("Error fetching messages: %v", err)
+	for s.IsProcessing() {
+		var backlog int64
+		if err := database.DB.Model(&models.Message{}).
+			Where("status IN ?", []string{models.StatusPending, models.StatusFailed}).
+			Count(&backlog).Error; err != nil {
+			slog.Warn("Failed to count message backlog", "error", err)
+		} else {
+			metrics.Backlog.Set(float64(backlog))
+		}
+
+		messages, tenants, err := s.selectBatch(context.Background())
+		if err != nil {
+			slog.Error("Error selecting messages", "error", err)
 			<-ticker.C
 			continue
 		}
@@ -88,102 +165,550 @@ func (s *MessageService) processMessages() {
 			continue
 		}
 
+		ctx, span := tracing.Tracer().Start(context.Background(), "processMessages",
+			trace.WithAttributes(attribute.Int("messaging.batch.size", len(messages))))
+
 		// Process messages in parallel with worker pool
 		var wg sync.WaitGroup
 		for i := range messages {
 			msg := &messages[i]
+			route := s.messageRoute(ctx, msg, tenants[msg.TenantID])
+			if route == nil {
+				continue
+			}
 			s.workers <- struct{}{}
 			wg.Add(1)
 			go func() {
+				metrics.WorkersInFlight.Inc()
 				defer func() {
+					metrics.WorkersInFlight.Dec()
 					<-s.workers
 					wg.Done()
 				}()
 
-				if err := s.sendMessageWithRetry(msg); err != nil {
-					log.Printf("Error sending message: %v", err)
-				}
+				s.processMessage(ctx, msg, route)
 			}()
 		}
 		wg.Wait()
+		span.End()
 
 		<-ticker.C
 	}
 }
 
-func (s *MessageService) sendMessageWithRetry(msg *models.Message) error {
+// messageRoute returns how a message is sent according to its tenant's
+// settings. When they are invalid the message is deferred, so it does not
+// take one of the tenant's batch slots every round, and nil is returned.
+func (s *MessageService) messageRoute(ctx context.Context, msg *models.Message, tenant *models.Tenant) *sendRoute {
+	route, err := tenantRoute(tenant)
+	if err == nil {
+		return route
+	}
+	messageLogger(msg).ErrorContext(ctx, "Invalid tenant settings, deferring message", "error", err, "retry_after", invalidTenantRetryDelay)
+	if err := s.deferMessage(ctx, msg, invalidTenantRetryDelay); err != nil {
+		messageLogger(msg).ErrorContext(ctx, "Error deferring message", "error", err)
+	}
+	return nil
+}
+
+// processMessage sends one message unless its recipient is suppressed; an
+// opt-out confirmation is sent regardless. Its span continues the trace the
+// message was created in and links to the batch.
+func (s *MessageService) processMessage(batchCtx context.Context, msg *models.Message, route *sendRoute) {
+	ctx, span := tracing.Tracer().Start(tracing.Extract(context.Background(), msg.TraceParent), "processMessage",
+		trace.WithLinks(trace.LinkFromContext(batchCtx)),
+		trace.WithAttributes(attribute.Int64("messaging.message.id", int64(msg.ID))))
+	defer span.End()
+
+	if !msg.OptOutConfirmation {
+		suppressed, err := isSuppressed(ctx, msg.TenantID, msg.To)
+		if err != nil {
+			// Never send when the suppression list cannot be checked
+			messageLogger(msg).ErrorContext(ctx, "Error checking suppression", "error", err)
+			return
+		}
+		if suppressed {
+			if err := s.markSuppressed(ctx, msg); err != nil {
+				messageLogger(msg).ErrorContext(ctx, "Error recording message suppression", "error", err)
+			}
+			return
+		}
+	}
+
+	if err := s.sendMessageWithRetry(ctx, msg, route); err != nil {
+		if errors.Is(err, errMessageClaimed) {
+			messageLogger(msg).InfoContext(ctx, "Message skipped, already being sent")
+			return
+		}
+		if errors.Is(err, ErrSendUnrecorded) {
+			// Marking it failed would send it again
+			messageLogger(msg).ErrorContext(ctx, "Message left for reconciliation", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			return
+		}
+
+		var limited *RateLimitedError
+		if errors.As(err, &limited) {
+			if err := s.deferMessage(ctx, msg, limited.RetryAfter); err != nil {
+				messageLogger(msg).ErrorContext(ctx, "Error deferring rate-limited message", "error", err)
+				return
+			}
+			messageLogger(msg).InfoContext(ctx, "Message deferred by rate limit", "retry_after", limited.RetryAfter)
+			return
+		}
+
+		var open *CircuitOpenError
+		if errors.As(err, &open) {
+			if err := s.deferMessage(ctx, msg, open.RetryAfter); err != nil {
+				messageLogger(msg).ErrorContext(ctx, "Error deferring message held by circuit breaker", "error", err)
+				return
+			}
+			messageLogger(msg).InfoContext(ctx, "Message deferred by open circuit", "provider", open.Provider, "retry_after", open.RetryAfter)
+			return
+		}
+
+		messageLogger(msg).ErrorContext(ctx, "Error sending message", "error", err)
+		span.SetStatus(codes.Error, err.Error())
+		gatewayName := route.gateway.Name
+		if msg.Provider != "" {
+			gatewayName = msg.Provider
+		}
+		metrics.MessagesFailed.WithLabelValues(gatewayName, errorClass(err)).Inc()
+		if err := s.markFailed(ctx, msg, err); err != nil {
+			messageLogger(msg).ErrorContext(ctx, "Error recording message failure", "error", err)
+		}
+	}
+}
+
+// sendMessageWithRetry sends a message through the gateways the router picks
+// for it, moving on to the next one after an error that shows the gateway
+// did not take the message and retrying the same one otherwise. Gateways
+// whose circuit is open are skipped; when none is left the message waits
+// for the first to recover.
+func (s *MessageService) sendMessageWithRetry(ctx context.Context, msg *models.Message, route *sendRoute) error {
+	gateways, err := routeProviders(route, msg)
+	if err != nil {
+		return err
+	}
+
+	budget := newSendBudget()
 	var lastErr error
+	current := 0
 	for i := 0; i < maxRetries; i++ {
-		if err := s.sendMessage(msg); err != nil {
+		gateway := gateways[current]
+		if !breakers.available(gateway.Name) {
+			// Another gateway may only take over when this one certainly
+			// did not get the message
+			if current == len(gateways)-1 || (lastErr != nil && !canFailOver(lastErr)) {
+				return &CircuitOpenError{Provider: gateway.Name, RetryAfter: breakers.retryAfter(gateway.Name)}
+			}
+			current++
+			i--
+			continue
+		}
+		if err := s.sendMessage(ctx, msg, route.via(gateway), budget); err != nil {
+			// Retrying straight away cannot succeed and only adds load
+			var limited *RateLimitedError
+			if errors.As(err, &limited) || errors.Is(err, errMessageClaimed) || errors.Is(err, ErrSendUnrecorded) {
+				return err
+			}
+			// Another worker took the probe; the circuit is checked again
+			var open *CircuitOpenError
+			if errors.As(err, &open) {
+				continue
+			}
 			lastErr = err
+			messageLogger(msg).WarnContext(ctx, "Send attempt failed", "try", i+1, "provider", gateway.Name, "error", err)
+			if i == maxRetries-1 {
+				break
+			}
+			metrics.MessagesRetried.WithLabelValues(gateway.Name, errorClass(err)).Inc()
+			if canFailOver(err) && current < len(gateways)-1 {
+				current++
+				metrics.ProviderFailovers.WithLabelValues(gateway.Name, gateways[current].Name).Inc()
+				messageLogger(msg).InfoContext(ctx, "Failing over to next provider", "from", gateway.Name, "to", gateways[current].Name)
+				continue
+			}
 			time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
 			continue
 		}
 		return nil
 	}
-	return fmt.Errorf("failed after %d retries: %v", maxRetries, lastErr)
+	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
-func (s *MessageService) sendMessage(msg *models.Message) error {
-	ctx := context.Background()
+// sendMessage hands a message to its channel's sender, which posts it to the
+// gateway of the route. The message is claimed before the request so that a
+// send which cannot be recorded afterwards is not repeated, and every
+// attempt carries the same idempotency key so the gateway can drop
+// duplicates. The recipient and throughput limits are only checked once the
+// message is claimed and the gateway's circuit lets it through, and only
+// the first time budget sees them.
+func (s *MessageService) sendMessage(ctx context.Context, msg *models.Message, route *sendRoute, budget *sendBudget) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "sendMessage",
+		trace.WithAttributes(attribute.Int64("messaging.message.id", int64(msg.ID))))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
-	// Check rate limit before sending
-	canSend, err := redis.CheckRateLimit(ctx, msg.To)
+	channelSender, err := senderFor(msg.Channel)
 	if err != nil {
-		log.Printf("Warning: Rate limit check failed: %v", err)
-	} else if !canSend {
-		return fmt.Errorf("rate limit exceeded for recipient %s", msg.To)
+		return err
 	}
 
-	payload := map[string]string{
-		"to":      msg.To,
-		"content": msg.Content,
-	}
+	gateway := route.gateway
 
-	jsonData, err := json.Marshal(payload)
+	claimCtx, claimSpan := tracing.Tracer().Start(ctx, "ClaimMessage")
+	previousStatus := msg.Status
+	msg.Provider = gateway.Name
+	err = claimMessage(claimCtx, msg)
+	claimSpan.End()
 	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %v", err)
+		return err
+	}
+	// release hands the claim back when the gateway certainly did not
+	// accept the message
+	release := func() {
+		if err := releaseMessage(context.WithoutCancel(ctx), msg, previousStatus); err != nil {
+			messageLogger(msg).WarnContext(ctx, "Failed to release message claim", "error", err)
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+	if !breakers.allow(gateway.Name) {
+		release()
+		return &CircuitOpenError{Provider: gateway.Name, RetryAfter: breakers.retryAfter(gateway.Name)}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if !budget.recipient {
+		limitCtx, limitSpan := tracing.Tracer().Start(ctx, "CheckRateLimit")
+		limit, err := redis.CheckRateLimit(limitCtx, msg.TenantID, msg.To, route.limit)
+		limitSpan.End()
+		if err != nil {
+			messageLogger(msg).WarnContext(ctx, "Rate limit check failed", "error", err)
+		} else if !limit.Allowed {
+			breakers.abandon(gateway.Name)
+			release()
+			return &RateLimitedError{Recipient: msg.To, RetryAfter: limit.RetryAfter}
+		}
+		budget.recipient = true
+	}
 
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %v", err)
+	// Pace sends to stay within the gateway's contracted throughput
+	if !budget.providers[gateway.Name] {
+		if err := waitForThroughput(ctx, gateway); err != nil {
+			breakers.abandon(gateway.Name)
+			release()
+			return fmt.Errorf("error waiting for throughput: %v", err)
+		}
+		budget.providers[gateway.Name] = true
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	start := time.Now()
+	providerID, err := channelSender.send(ctx, s.client, gateway, msg)
+	breakers.record(gateway.Name, err)
+	if err != nil {
+		if err := recordAttempt(database.DB.WithContext(context.WithoutCancel(ctx)), msg, gateway, start, err); err != nil {
+			messageLogger(msg).WarnContext(ctx, "Failed to record delivery attempt", "error", err)
+		}
+		// The gateway may have accepted the message before the connection
+		// broke; retries reuse the idempotency key so it can drop the repeat
+		release()
+		return err
 	}
 
-	msg.MessageID = uuid.New().String()
+	msg.MessageID = providerID
 	msg.Sent = true
 	msg.SentAt = time.Now()
+	msg.Status = models.StatusSent
+	msg.LastError = ""
+	msg.NextAttemptAt = nil
 
 	// Cache the sent message
-	if err := redis.CacheMessage(ctx, msg); err != nil {
-		log.Printf("Warning: Failed to cache message: %v", err)
+	cacheCtx, cacheSpan := tracing.Tracer().Start(ctx, "CacheMessage")
+	if err := redis.CacheMessage(cacheCtx, msg); err != nil {
+		messageLogger(msg).WarnContext(ctx, "Failed to cache message", "error", err)
 	}
+	cacheSpan.End()
 
-	// Update the message in the database
-	if err := database.DB.Save(msg).Error; err != nil {
-		return fmt.Errorf("error updating message status: %v", err)
+	// Update the message and record the event in the same transaction
+	saveCtx, saveSpan := tracing.Tracer().Start(ctx, "SaveMessage")
+	err = database.DB.WithContext(saveCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(msg).Error; err != nil {
+			return err
+		}
+		if err := recordAttempt(tx, msg, gateway, start, nil); err != nil {
+			return err
+		}
+		return recordStatusChange(tx, msg, models.EventMessageSent)
+	})
+	saveSpan.End()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSendUnrecorded, err)
 	}
 
+	messageLogger(msg).InfoContext(ctx, "Message sent", "provider", gateway.Name, "provider_message_id", msg.MessageID)
+	metrics.MessagesSent.WithLabelValues(gateway.Name).Inc()
+	if msg.Channel == "" || msg.Channel == models.ChannelSMS {
+		metrics.SegmentsSent.WithLabelValues(gateway.Name).Add(float64(msg.Segments))
+	}
+	metrics.QueueLatency.Observe(msg.SentAt.Sub(msg.CreatedAt).Seconds())
+
 	return nil
 }
 
-func (s *MessageService) GetSentMessages() ([]models.Message, error) {
+// messageLogger returns a logger carrying the fields that identify a
+// message. attempt is the processing round, counting from 1.
+func messageLogger(msg *models.Message) *slog.Logger {
+	return slog.With(
+		slog.Uint64("message_id", uint64(msg.ID)),
+		slog.Uint64("tenant_id", uint64(msg.TenantID)),
+		slog.String("channel", msg.Channel),
+		logging.Recipient(msg.To),
+		slog.Int("attempt", msg.Attempts+1),
+	)
+}
+
+// providerMessageID returns the gateway's ID for an accepted message so
+// delivery receipts can be matched later, or a generated ID if the gateway
+// did not return one
+func providerMessageID(resp *http.Response) string {
+	var body struct {
+		MessageID      string `json:"messageId"`
+		MessageIDSnake string `json:"message_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil {
+		if body.MessageID != "" {
+			return body.MessageID
+		}
+		if body.MessageIDSnake != "" {
+			return body.MessageIDSnake
+		}
+	}
+	return uuid.New().String()
+}
+
+// claimMessage durably moves a pending or failed message to sending before
+// its gateway is called, giving it a send token on its first attempt and
+// recording the provider it is sent through
+func claimMessage(ctx context.Context, msg *models.Message) error {
+	if msg.SendToken == "" {
+		msg.SendToken = uuid.New().String()
+	}
+	now := time.Now()
+	result := database.DB.WithContext(ctx).Model(&models.Message{}).
+		Where("id = ? AND status IN ?", msg.ID, []string{models.StatusPending, models.StatusFailed}).
+		Updates(map[string]interface{}{
+			"status":     models.StatusSending,
+			"send_token": msg.SendToken,
+			"sending_at": now,
+			"provider":   msg.Provider,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error claiming message: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errMessageClaimed
+	}
+	msg.Status = models.StatusSending
+	msg.SendingAt = &now
+	return nil
+}
+
+// releaseMessage returns a claimed message to the status it was claimed from
+func releaseMessage(ctx context.Context, msg *models.Message, status string) error {
+	msg.Status = status
+	msg.SendingAt = nil
+	return database.DB.WithContext(ctx).Model(&models.Message{}).
+		Where("id = ? AND status = ? AND send_token = ?", msg.ID, models.StatusSending, msg.SendToken).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"sending_at": nil,
+		}).Error
+}
+
+// markFailed records a failed processing round, dead-lettering the message
+// once it has used up its attempts
+func (s *MessageService) markFailed(ctx context.Context, msg *models.Message, sendErr error) error {
+	eventType := countFailure(msg, sendErr)
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(msg).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, msg, eventType)
+	})
+}
+
+// countFailure records a failed processing round on msg and returns the
+// event it causes
+func countFailure(msg *models.Message, sendErr error) string {
+	msg.Attempts++
+	msg.LastError = sendErr.Error()
+	msg.Status = models.StatusFailed
+	if msg.Attempts >= maxSendAttempts {
+		msg.Status = models.StatusDeadLettered
+		return models.EventMessageDeadLettered
+	}
+	return models.EventMessageFailed
+}
+
+// deferMessage holds a message back, e.g. until its rate limiter allows it
+// again. Attempts are left untouched and no event is recorded since the
+// message's status does not change.
+func (s *MessageService) deferMessage(ctx context.Context, msg *models.Message, retryAfter time.Duration) error {
+	next := time.Now().Add(retryAfter)
+	msg.NextAttemptAt = &next
+	return database.DB.WithContext(ctx).Model(msg).Update("next_attempt_at", next).Error
+}
+
+// markSuppressed moves a message to the terminal suppressed state
+func (s *MessageService) markSuppressed(ctx context.Context, msg *models.Message) error {
+	msg.Status = models.StatusSuppressed
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(msg).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(tx, msg, models.EventMessageSuppressed)
+	})
+}
+
+// recordStatusChange writes the outbox event and queues client webhooks for
+// a message change using the caller's transaction. A change that ends the
+// message without delivery starts its fallback, if it has one.
+func recordStatusChange(tx *gorm.DB, msg *models.Message, eventType string) error {
+	if err := recordEvent(tx, msg, eventType); err != nil {
+		return err
+	}
+	if err := enqueueStatusWebhooks(tx, msg, eventType); err != nil {
+		return err
+	}
+	if fallbackEvents[eventType] {
+		return startFallback(tx, msg, metrics.FallbackFailed)
+	}
+	return nil
+}
+
+// CreateMessage validates and stores a new unsent message together with its
+// creation event. msg carries the tenant, channel, recipient and content;
+// its channel defaults to SMS, and its callback URL, which receives
+// status-change webhooks for this message and its fallbacks, and its
+// fallback chain are optional. The trace in ctx is
+// remembered so that sending the message joins it.
+func (s *MessageService) CreateMessage(ctx context.Context, msg *models.Message) error {
+	return s.createMessage(ctx, msg)
+}
+
+// CreateMessageFromTemplate renders one of the tenant's templates for a
+// locale into msg's content and stores it like CreateMessage. Every variable
+// the template uses must be given, and the rendered content must fit a
+// message.
+func (s *MessageService) CreateMessageFromTemplate(ctx context.Context, msg *models.Message, templateID uint, locale string, variables map[string]string) error {
+	tmpl, err := getTemplate(database.DB.WithContext(ctx), msg.TenantID, templateID)
+	if err != nil {
+		return err
+	}
+	content, err := renderTemplate(templateBody(tmpl, locale), variables)
+	if err != nil {
+		return err
+	}
+
+	msg.Content = content
+	msg.TemplateID = &tmpl.ID
+	return s.createMessage(ctx, msg)
+}
+
+// createMessage validates a new message with its channel's sender and
+// stores it. A channel without a gateway is rejected up front.
+func (s *MessageService) createMessage(ctx context.Context, msg *models.Message) error {
+	if msg.Channel == "" {
+		msg.Channel = models.ChannelSMS
+	}
+	channelSender, err := senderFor(msg.Channel)
+	if err != nil {
+		return err
+	}
+	if _, ok := provider.ForChannel(msg.Channel); !ok {
+		return fmt.Errorf("%w: no provider is configured for %s messages", ErrInvalidMessage, msg.Channel)
+	}
+	if strings.TrimSpace(msg.To) == "" {
+		return fmt.Errorf("%w: recipient cannot be empty", ErrInvalidMessage)
+	}
+	if err := channelSender.validate(msg); err != nil {
+		return err
+	}
+	if err := prepareFallbacks(msg); err != nil {
+		return err
+	}
+	if msg.CallbackURL != "" {
+		if err := validateCallbackURL(msg.CallbackURL); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+	}
+
+	msg.Status = models.StatusPending
+	msg.TraceParent = tracing.Inject(ctx)
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return insertMessage(tx, msg)
+	})
+	if err != nil {
+		return fmt.Errorf("error creating message: %v", err)
+	}
+	return nil
+}
+
+// maxSegments returns how many SMS segments a message may be split into,
+// from MESSAGE_MAX_SEGMENTS
+func maxSegments() (int, error) {
+	value := getEnv("MESSAGE_MAX_SEGMENTS", "3")
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid MESSAGE_MAX_SEGMENTS %q", value)
+	}
+	return limit, nil
+}
+
+// insertMessage stores a new message and its creation event using the
+// caller's transaction, recording where an SMS goes and how many segments
+// it is billed as
+func insertMessage(tx *gorm.DB, msg *models.Message) error {
+	if msg.Channel == "" {
+		msg.Channel = models.ChannelSMS
+	}
+	msg.Segments = 1
+	if msg.Channel == models.ChannelSMS {
+		if msg.CountryCode == 0 {
+			if number, err := normalizeRecipient(msg.To); err == nil {
+				msg.To, msg.CountryCode, msg.Region = number.E164, number.CountryCode, number.Region
+			}
+		}
+		info := sms.Analyze(msg.Content)
+		msg.Encoding = string(info.Encoding)
+		msg.Segments = info.Segments
+	}
+	if err := tx.Create(msg).Error; err != nil {
+		return err
+	}
+	return recordStatusChange(tx, msg, models.EventMessageCreated)
+}
+
+// GetSentMessages returns a tenant's sent messages as cached when they were
+// sent. A non-empty channel returns only that channel's messages.
+func (s *MessageService) GetSentMessages(tenantID uint, channel string) ([]models.Message, error) {
 	var messages []models.Message
 
+	query := database.DB.Where("tenant_id = ? AND sent = ?", tenantID, true)
+	if channel != "" {
+		if !provider.ValidChannel(channel) {
+			return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidMessage, channel)
+		}
+		query = query.Where("channel = ?", channel)
+	}
+
 	// Try to get from database
-	if err := database.DB.Where("sent = ?", true).Find(&messages).Error; err != nil {
+	if err := query.Find(&messages).Error; err != nil {
 		return nil, fmt.Errorf("error fetching sent messages: %v", err)
 	}
 
@@ -201,9 +726,9 @@ func (s *MessageService) GetSentMessages() ([]models.Message, error) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			cachedMsg, err := redis.GetCachedMessage(ctx, msg.MessageID)
+			cachedMsg, err := redis.GetCachedMessage(ctx, tenantID, msg.MessageID)
 			if err != nil {
-				log.Printf("Warning: Failed to get cached message %s: %v", msg.MessageID, err)
+				slog.WarnContext(ctx, "Failed to get cached message", "provider_message_id", msg.MessageID, "error", err)
 				return
 			}
 			if cachedMsg != nil {
@@ -224,3 +749,30 @@ func (s *MessageService) GetSentMessages() ([]models.Message, error) {
 
 	return result, nil
 }
+
+// GetDeliveryAttempts returns the gateway requests made for one of a
+// tenant's messages, oldest first
+func (s *MessageService) GetDeliveryAttempts(tenantID, messageID uint) ([]models.DeliveryAttempt, error) {
+	var count int64
+	if err := database.DB.Model(&models.Message{}).
+		Where("id = ? AND tenant_id = ?", messageID, tenantID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("error fetching message: %v", err)
+	}
+	if count == 0 {
+		return nil, ErrMessageNotFound
+	}
+
+	var attempts []models.DeliveryAttempt
+	if err := database.DB.Where("message_id = ?", messageID).Order("id").Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("error fetching delivery attempts: %v", err)
+	}
+	return attempts, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
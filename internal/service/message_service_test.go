@@ -2,10 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
 	"github.com/vkukul/messaging-system/pkg/database"
 	"github.com/vkukul/messaging-system/pkg/redis"
 )
@@ -89,14 +97,14 @@ func TestSendMessage(t *testing.T) {
 	err := database.DB.Create(msg).Error
 	assert.NoError(t, err)
 
-	err = service.sendMessage(msg)
+	err = service.sendMessage(context.Background(), msg, defaultRoute(), newSendBudget())
 	assert.NoError(t, err)
 	assert.True(t, msg.Sent)
 	assert.NotEmpty(t, msg.MessageID)
 	assert.NotZero(t, msg.SentAt)
 
 	// Verify message was cached in Redis
-	cached, err := redis.GetCachedMessage(ctx, msg.MessageID)
+	cached, err := redis.GetCachedMessage(ctx, msg.TenantID, msg.MessageID)
 	assert.NoError(t, err)
 	assert.NotNil(t, cached)
 	assert.Equal(t, msg.ID, cached.ID)
@@ -127,12 +135,12 @@ func TestGetSentMessages(t *testing.T) {
 	for _, msg := range testMessages {
 		err := database.DB.Create(msg).Error
 		assert.NoError(t, err)
-		err = service.sendMessage(msg)
+		err = service.sendMessage(context.Background(), msg, defaultRoute(), newSendBudget())
 		assert.NoError(t, err)
 	}
 
 	// Get sent messages
-	messages, err := service.GetSentMessages()
+	messages, err := service.GetSentMessages(models.DefaultTenantID, "")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, messages)
 
@@ -148,3 +156,253 @@ func TestGetSentMessages(t *testing.T) {
 		database.DB.Unscoped().Delete(msg)
 	}
 }
+
+func TestMarkFailed(t *testing.T) {
+	setupTest(t)
+	service := NewMessageService()
+
+	msg := &models.Message{
+		To:      "+905551234567",
+		Content: "Test message",
+		Status:  models.StatusPending,
+	}
+	err := database.DB.Create(msg).Error
+	assert.NoError(t, err)
+
+	for i := 1; i < maxSendAttempts; i++ {
+		assert.NoError(t, service.markFailed(context.Background(), msg, fmt.Errorf("gateway unavailable")))
+		assert.Equal(t, models.StatusFailed, msg.Status)
+		assert.Equal(t, i, msg.Attempts)
+	}
+
+	assert.NoError(t, service.markFailed(context.Background(), msg, fmt.Errorf("gateway unavailable")))
+	assert.Equal(t, models.StatusDeadLettered, msg.Status)
+	assert.Equal(t, "gateway unavailable", msg.LastError)
+
+	var events int64
+	database.DB.Model(&models.OutboxEvent{}).
+		Where("aggregate_id = ? AND event_type = ?", msg.ID, models.EventMessageDeadLettered).
+		Count(&events)
+	assert.Equal(t, int64(1), events)
+
+	// Clean up
+	database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+	database.DB.Unscoped().Delete(msg)
+}
+
+func TestSendMessageClaim(t *testing.T) {
+	setupTest(t)
+	service := NewMessageService()
+	ctx := context.Background()
+
+	status := http.StatusInternalServerError
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(provider.IdempotencyHeader))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	route := &sendRoute{gateway: &provider.Config{Name: "test", URL: server.URL}}
+
+	msg := &models.Message{To: "+905553333333", Content: "Claimed", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(msg).Error)
+
+	// A rejected send hands the claim back but keeps the send token
+	assert.Error(t, service.sendMessage(ctx, msg, route, newSendBudget()))
+	var stored models.Message
+	assert.NoError(t, database.DB.First(&stored, msg.ID).Error)
+	assert.Equal(t, models.StatusPending, stored.Status)
+	assert.Nil(t, stored.SendingAt)
+	assert.NotEmpty(t, stored.SendToken)
+
+	// A message claimed elsewhere is not sent
+	claimed := stored
+	assert.NoError(t, claimMessage(ctx, &claimed))
+	err := service.sendMessage(ctx, &stored, route, newSendBudget())
+	assert.True(t, errors.Is(err, errMessageClaimed))
+	assert.Len(t, keys, 1)
+
+	assert.NoError(t, releaseMessage(ctx, &claimed, models.StatusPending))
+	status = http.StatusOK
+	assert.NoError(t, service.sendMessage(ctx, msg, route, newSendBudget()))
+	assert.Equal(t, models.StatusSent, msg.Status)
+
+	// Every attempt carried the same idempotency key
+	assert.Equal(t, []string{msg.SendToken, msg.SendToken}, keys)
+
+	// Clean up
+	database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+	database.DB.Unscoped().Delete(msg)
+}
+
+// setupLocalRedis points the Redis client at an in-process stand-in so
+// rate limit behaviour can be tested without a Redis server
+func setupLocalRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	previous := redis.Client
+	redis.Client = goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		redis.Client.Close()
+		redis.Client = previous
+	})
+	return mr
+}
+
+func TestSendMessageRateLimited(t *testing.T) {
+	setupTest(t)
+	mr := setupLocalRedis(t)
+	t.Setenv("RATE_LIMIT_RECIPIENT", "sliding_window:1/1m")
+	service := NewMessageService()
+	ctx := context.Background()
+
+	recipient := "+905551111111"
+	// Use up the recipient's allowance
+	result, err := redis.CheckRateLimit(ctx, models.DefaultTenantID, recipient, nil)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	msg := &models.Message{TenantID: models.DefaultTenantID, To: recipient, Content: "Rate limited", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(msg).Error)
+	err = service.sendMessageWithRetry(ctx, msg, defaultRoute())
+
+	var limited *RateLimitedError
+	assert.True(t, errors.As(err, &limited))
+	assert.Equal(t, recipient, limited.Recipient)
+	assert.Greater(t, limited.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, limited.RetryAfter, time.Minute)
+
+	// The denied send was neither retried into the limiter nor counted, and
+	// its claim was handed back
+	key := redis.RateLimitPrefix + redis.ClassRecipient + ":1:" + recipient
+	members, err := mr.ZMembers(key)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, 0, msg.Attempts)
+	assert.False(t, msg.Sent)
+	var stored models.Message
+	assert.NoError(t, database.DB.First(&stored, msg.ID).Error)
+	assert.Equal(t, models.StatusPending, stored.Status)
+
+	// Clean up
+	database.DB.Unscoped().Delete(msg)
+}
+
+func TestMessageRouteDefersInvalidTenant(t *testing.T) {
+	setupTest(t)
+	service := NewMessageService()
+	ctx := context.Background()
+
+	msg := &models.Message{TenantID: models.DefaultTenantID, To: "+905551111112", Content: "Misconfigured", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(msg).Error)
+
+	route := service.messageRoute(ctx, msg, &models.Tenant{ID: models.DefaultTenantID, Provider: "missing"})
+	assert.Nil(t, route)
+
+	// The message waits instead of being picked up again next round
+	var stored models.Message
+	assert.NoError(t, database.DB.First(&stored, msg.ID).Error)
+	assert.Equal(t, models.StatusPending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+	if assert.NotNil(t, stored.NextAttemptAt) {
+		assert.WithinDuration(t, time.Now().Add(invalidTenantRetryDelay), *stored.NextAttemptAt, time.Minute)
+	}
+
+	route = service.messageRoute(ctx, msg, &models.Tenant{ID: models.DefaultTenantID})
+	assert.NotNil(t, route)
+
+	// Clean up
+	database.DB.Unscoped().Delete(msg)
+}
+
+func TestSendMessageCountsLimitsOnce(t *testing.T) {
+	setupTest(t)
+	mr := setupLocalRedis(t)
+	useBreakers(t, defaultBreakerSettings)
+	t.Setenv("RATE_LIMIT_RECIPIENT", "sliding_window:10/1m")
+	service := NewMessageService()
+	ctx := context.Background()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	route := &sendRoute{gateway: &provider.Config{Name: "limits-once-test", URL: server.URL}, pinned: true}
+
+	recipient := "+905554444444"
+	msg := &models.Message{TenantID: models.DefaultTenantID, To: recipient, Content: "Retried", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(msg).Error)
+
+	// A message claimed elsewhere uses none of the recipient's allowance
+	claimed := *msg
+	assert.NoError(t, claimMessage(ctx, &claimed))
+	assert.ErrorIs(t, service.sendMessageWithRetry(ctx, msg, route), errMessageClaimed)
+	key := redis.RateLimitPrefix + redis.ClassRecipient + ":1:" + recipient
+	assert.False(t, mr.Exists(key))
+	assert.NoError(t, releaseMessage(ctx, &claimed, models.StatusPending))
+	msg.Status = models.StatusPending
+
+	// Retries through the same gateway count once
+	assert.Error(t, service.sendMessageWithRetry(ctx, msg, route))
+	assert.Equal(t, maxRetries, requests)
+	members, err := mr.ZMembers(key)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+
+	// Clean up
+	database.DB.Where("message_id = ?", msg.ID).Delete(&models.DeliveryAttempt{})
+	database.DB.Unscoped().Delete(msg)
+}
+
+func TestRateLimitIsolatedPerTenant(t *testing.T) {
+	setupLocalRedis(t)
+	t.Setenv("RATE_LIMIT_RECIPIENT", "sliding_window:1/1m")
+	ctx := context.Background()
+
+	recipient := "+905552222222"
+	result, err := redis.CheckRateLimit(ctx, 1, recipient, nil)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// Another tenant has its own allowance for the same recipient
+	result, err = redis.CheckRateLimit(ctx, 2, recipient, nil)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// A tenant limit replaces the configured one
+	route, err := tenantRoute(&models.Tenant{ID: 3, RateLimit: "sliding_window:2/1m"})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		result, err = redis.CheckRateLimit(ctx, 3, recipient, route.limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+	result, err = redis.CheckRateLimit(ctx, 3, recipient, route.limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+}
+
+func TestMaxSegments(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "3", want: 3},
+		{value: "10", want: 10},
+		{value: "0", wantErr: true},
+		{value: "many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("MESSAGE_MAX_SEGMENTS", tt.value)
+		got, err := maxSegments()
+		if tt.wantErr {
+			assert.Error(t, err, "value=%q", tt.value)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+)
+
+// Error classes used to label send failures
+const (
+	errorClassRateLimited = "rate_limited"
+	errorClassTimeout     = "timeout"
+	errorClassNetwork     = "network"
+	errorClassClient      = "client_error"
+	errorClassServer      = "server_error"
+	errorClassInternal    = "internal"
+)
+
+// gatewayStatusError is returned when a provider answers with a non-2xx status
+type gatewayStatusError struct {
+	StatusCode int
+}
+
+func (e *gatewayStatusError) Error() string {
+	return "unexpected status code: " + strconv.Itoa(e.StatusCode)
+}
+
+// errorClass buckets a send error into a small set of metric label values
+func errorClass(err error) string {
+	var limited *RateLimitedError
+	if errors.As(err, &limited) {
+		return errorClassRateLimited
+	}
+
+	var status *gatewayStatusError
+	if errors.As(err, &status) {
+		if status.StatusCode >= 500 {
+			return errorClassServer
+		}
+		return errorClassClient
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return errorClassTimeout
+		}
+		return errorClassNetwork
+	}
+	return errorClassInternal
+}
+
+// observeWebhook records the latency of an outbound webhook request
+func observeWebhook(destination string, start time.Time, resp *http.Response, err error) {
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.WebhookLatency.WithLabelValues(destination, status).Observe(time.Since(start).Seconds())
+}
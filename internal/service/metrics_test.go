@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Rate limited",
+			err:  &RateLimitedError{Recipient: "+905551111111", RetryAfter: time.Second},
+			want: errorClassRateLimited,
+		},
+		{
+			name: "Gateway rejected request",
+			err:  fmt.Errorf("failed after 3 retries: %w", &gatewayStatusError{StatusCode: 400}),
+			want: errorClassClient,
+		},
+		{
+			name: "Gateway error",
+			err:  &gatewayStatusError{StatusCode: 503},
+			want: errorClassServer,
+		},
+		{
+			name: "Deadline exceeded",
+			err:  fmt.Errorf("error sending request: %w", context.DeadlineExceeded),
+			want: errorClassTimeout,
+		},
+		{
+			name: "Connection refused",
+			err:  fmt.Errorf("error sending request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}),
+			want: errorClassNetwork,
+		},
+		{
+			name: "Anything else",
+			err:  errors.New("error marshaling JSON"),
+			want: errorClassInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorClass(tt.err))
+		})
+	}
+}
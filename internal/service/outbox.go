@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+const (
+	outboxBatchSize    = 100
+	outboxPollInterval = 5 * time.Second
+	// outboxClaimTimeout is how long a relay holds the events it claimed
+	// before another replica may take them over
+	outboxClaimTimeout = time.Minute
+	// outboxMaxAttempts is how many times an event may fail to publish
+	// before it is given up on so that later events can go out
+	outboxMaxAttempts = 10
+)
+
+// messageEventPayload is the body stored with every message outbox event
+// and sent with client webhooks
+type messageEventPayload struct {
+	OccurredAt     time.Time              `json:"occurred_at"`
+	Message        *models.Message        `json:"message,omitempty"`
+	InboundMessage *models.InboundMessage `json:"inbound_message,omitempty"`
+}
+
+// recordEvent writes a message event to the outbox using the caller's transaction
+func recordEvent(tx *gorm.DB, msg *models.Message, eventType string) error {
+	return recordOutboxEvent(tx, "message", msg.ID, eventType, messageEventPayload{
+		OccurredAt: time.Now(),
+		Message:    msg,
+	})
+}
+
+func recordOutboxEvent(tx *gorm.DB, aggregateType string, aggregateID uint, eventType string, payload messageEventPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling outbox payload: %v", err)
+	}
+
+	event := &models.OutboxEvent{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       string(data),
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return fmt.Errorf("error recording %s event: %v", eventType, err)
+	}
+	return nil
+}
+
+// OutboxRelay publishes undispatched outbox events to a sink
+type OutboxRelay struct {
+	sink     OutboxSink
+	interval time.Duration
+}
+
+func NewOutboxRelay(sink OutboxSink) *OutboxRelay {
+	return &OutboxRelay{
+		sink:     sink,
+		interval: outboxPollInterval,
+	}
+}
+
+// Run polls the outbox until the context is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		dispatched, err := r.dispatchPending(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error dispatching outbox events", "error", err)
+		}
+
+		// Keep draining without waiting while full batches are coming back
+		if err == nil && dispatched == outboxBatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchPending publishes one batch of events in order. The batch is
+// claimed in a short transaction and published after it commits, so no row
+// locks are held across network calls.
+func (r *OutboxRelay) dispatchPending(ctx context.Context) (int, error) {
+	events, err := claimOutboxEvents(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for i := range events {
+		event := &events[i]
+		if err := r.sink.Publish(ctx, event); err != nil {
+			slog.WarnContext(ctx, "Failed to publish outbox event",
+				"event_id", event.ID, "event_type", event.EventType, "error", err)
+			givenUp, err := recordPublishFailure(ctx, event, err)
+			if err != nil {
+				return dispatched, err
+			}
+			if givenUp {
+				continue
+			}
+			// Stop at the first failure so later events are not published
+			// out of order, and hand the rest of the batch back
+			return dispatched, releaseOutboxEvents(ctx, events[i+1:])
+		}
+
+		if err := database.DB.WithContext(ctx).Model(event).Updates(map[string]interface{}{
+			"dispatched":    true,
+			"dispatched_at": time.Now(),
+			"claimed_until": nil,
+		}).Error; err != nil {
+			return dispatched, fmt.Errorf("error marking outbox event %d dispatched: %v", event.ID, err)
+		}
+		dispatched++
+	}
+	return dispatched, nil
+}
+
+// claimOutboxEvents claims the oldest pending events that no relay holds.
+// Rows are locked with SKIP LOCKED while claiming so several replicas can run
+// the relay side by side.
+func claimOutboxEvents(ctx context.Context) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched = ? AND failed_at IS NULL", false).
+			Where("(claimed_until IS NULL OR claimed_until < ?)", now).
+			Order("id").
+			Limit(outboxBatchSize).
+			Find(&events).Error; err != nil {
+			return fmt.Errorf("error fetching outbox events: %v", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		if err := tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).
+			Update("claimed_until", now.Add(outboxClaimTimeout)).Error; err != nil {
+			return fmt.Errorf("error claiming outbox events: %v", err)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// recordPublishFailure counts a failed publish and releases the event's
+// claim. It reports whether the event used up its attempts and was given up
+// on.
+func recordPublishFailure(ctx context.Context, event *models.OutboxEvent, publishErr error) (bool, error) {
+	event.Attempts++
+	updates := map[string]interface{}{
+		"attempts":      event.Attempts,
+		"last_error":    publishErr.Error(),
+		"claimed_until": nil,
+	}
+	givenUp := event.Attempts >= outboxMaxAttempts
+	if givenUp {
+		updates["failed_at"] = time.Now()
+	}
+	if err := database.DB.WithContext(ctx).Model(event).Updates(updates).Error; err != nil {
+		return false, fmt.Errorf("error recording outbox event %d failure: %v", event.ID, err)
+	}
+	if givenUp {
+		slog.ErrorContext(ctx, "Outbox event failed too often and was given up on",
+			"event_id", event.ID, "event_type", event.EventType, "attempts", event.Attempts)
+	}
+	return givenUp, nil
+}
+
+// releaseOutboxEvents hands claimed events back so the next poll takes them
+func releaseOutboxEvents(ctx context.Context, events []models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	if err := database.DB.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id IN ?", ids).
+		Update("claimed_until", nil).Error; err != nil {
+		return fmt.Errorf("error releasing outbox events: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+const natsTimeout = 5 * time.Second
+
+// OutboxSink publishes outbox events to an external system
+type OutboxSink interface {
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+// outboxEnvelope is what sinks put on the wire for each event
+type outboxEnvelope struct {
+	ID            uint            `json:"id"`
+	EventType     string          `json:"event_type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uint            `json:"aggregate_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func encodeEvent(event *models.OutboxEvent) ([]byte, error) {
+	data, err := json.Marshal(outboxEnvelope{
+		ID:            event.ID,
+		EventType:     event.EventType,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		CreatedAt:     event.CreatedAt,
+		Payload:       json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling outbox event: %v", err)
+	}
+	return data, nil
+}
+
+// NewOutboxSinkFromEnv builds the sink selected by OUTBOX_SINK
+func NewOutboxSinkFromEnv() (OutboxSink, error) {
+	switch kind := getEnv("OUTBOX_SINK", "redis"); kind {
+	case "redis":
+		return NewRedisSink(getEnv("OUTBOX_REDIS_CHANNEL", "messaging.events")), nil
+	case "webhook":
+		webhook := getEnv("OUTBOX_WEBHOOK_URL", "")
+		if webhook == "" {
+			return nil, fmt.Errorf("OUTBOX_WEBHOOK_URL is required for the webhook outbox sink")
+		}
+		return NewWebhookSink(webhook, newHTTPClient()), nil
+	case "nats":
+		return NewNATSSink(getEnv("OUTBOX_NATS_URL", "nats://localhost:4222"), getEnv("OUTBOX_NATS_SUBJECT", "messaging.events")), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox sink: %s", kind)
+	}
+}
+
+// RedisSink publishes events to a Redis pub/sub channel
+type RedisSink struct {
+	channel string
+}
+
+func NewRedisSink(channel string) *RedisSink {
+	return &RedisSink{channel: channel}
+}
+
+func (s *RedisSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := encodeEvent(event)
+	if err != nil {
+		return err
+	}
+	return redis.Publish(ctx, s.channel, string(data))
+}
+
+// WebhookSink POSTs events to an HTTP endpoint
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, client: client}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := encodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatUint(uint64(event.ID), 10))
+	req.Header.Set("X-Event-Type", event.EventType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NATSSink publishes events to a NATS-compatible server using the plain
+// text protocol. Each event goes to "<subject>.<event type>".
+type NATSSink struct {
+	url     string
+	subject string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewNATSSink(url, subject string) *NATSSink {
+	return &NATSSink{url: url, subject: subject}
+}
+
+func (s *NATSSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	data, err := encodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	s.setDeadline(ctx)
+
+	subject := s.subject + "." + event.EventType
+	if _, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data); err != nil {
+		s.close()
+		return fmt.Errorf("error publishing to nats: %v", err)
+	}
+
+	// The PONG confirms the server has processed the PUB before it
+	if err := s.awaitPong(); err != nil {
+		s.close()
+		return err
+	}
+	return nil
+}
+
+func (s *NATSSink) connect(ctx context.Context) error {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return fmt.Errorf("invalid nats url: %v", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %v", err)
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	s.setDeadline(ctx)
+
+	line, err := s.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		s.close()
+		return fmt.Errorf("unexpected nats greeting: %q %v", line, err)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "messaging-system",
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connectData, err := json.Marshal(options)
+	if err != nil {
+		s.close()
+		return fmt.Errorf("error marshaling nats options: %v", err)
+	}
+	if _, err := fmt.Fprintf(s.conn, "CONNECT %s\r\n", connectData); err != nil {
+		s.close()
+		return fmt.Errorf("error sending nats connect: %v", err)
+	}
+	return nil
+}
+
+func (s *NATSSink) awaitPong() error {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("error reading from nats: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "PING"):
+			if _, err := fmt.Fprint(s.conn, "PONG\r\n"); err != nil {
+				return fmt.Errorf("error answering nats ping: %v", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (s *NATSSink) setDeadline(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsTimeout)
+	}
+	s.conn.SetDeadline(deadline)
+}
+
+func (s *NATSSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn = nil
+	s.reader = nil
+}
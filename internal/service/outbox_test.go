@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func testOutboxEvent() *models.OutboxEvent {
+	return &models.OutboxEvent{
+		ID:            42,
+		AggregateType: "message",
+		AggregateID:   7,
+		EventType:     models.EventMessageSent,
+		Payload:       `{"message":{"id":7,"to":"+905551234567"}}`,
+	}
+}
+
+func TestEncodeEvent(t *testing.T) {
+	data, err := encodeEvent(testOutboxEvent())
+	assert.NoError(t, err)
+
+	var envelope map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &envelope))
+	assert.Equal(t, float64(42), envelope["id"])
+	assert.Equal(t, models.EventMessageSent, envelope["event_type"])
+	assert.Equal(t, float64(7), envelope["aggregate_id"])
+
+	payload := envelope["payload"].(map[string]interface{})
+	assert.Equal(t, "+905551234567", payload["message"].(map[string]interface{})["to"])
+}
+
+func TestWebhookSinkPublish(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{
+			name:       "Successfully publish event",
+			statusCode: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:       "Fail on non-2xx response",
+			statusCode: http.StatusInternalServerError,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHeaders http.Header
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeaders = r.Header
+				gotBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			sink := NewWebhookSink(server.URL, server.Client())
+			err := sink.Publish(context.Background(), testOutboxEvent())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "42", gotHeaders.Get("X-Event-ID"))
+			assert.Equal(t, models.EventMessageSent, gotHeaders.Get("X-Event-Type"))
+			assert.Contains(t, string(gotBody), `"event_type":"message.sent"`)
+		})
+	}
+}
+
+func TestNATSSinkPublish(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	published := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "PUB"):
+				payload, _ := reader.ReadString('\n')
+				published <- line + payload
+			case strings.HasPrefix(line, "PING"):
+				fmt.Fprint(conn, "PONG\r\n")
+			}
+		}
+	}()
+
+	sink := NewNATSSink("nats://"+listener.Addr().String(), "messaging.events")
+	assert.NoError(t, sink.Publish(context.Background(), testOutboxEvent()))
+
+	got := <-published
+	assert.True(t, strings.HasPrefix(got, "PUB messaging.events.message.sent "))
+	assert.Contains(t, got, `"aggregate_id":7`)
+}
+
+func TestNewOutboxSinkFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "Redis sink",
+			env:  map[string]string{"OUTBOX_SINK": "redis"},
+			want: &RedisSink{},
+		},
+		{
+			name: "Webhook sink",
+			env:  map[string]string{"OUTBOX_SINK": "webhook", "OUTBOX_WEBHOOK_URL": "http://localhost/events"},
+			want: &WebhookSink{},
+		},
+		{
+			name:    "Webhook sink without URL",
+			env:     map[string]string{"OUTBOX_SINK": "webhook", "OUTBOX_WEBHOOK_URL": ""},
+			wantErr: true,
+		},
+		{
+			name: "NATS sink",
+			env:  map[string]string{"OUTBOX_SINK": "nats"},
+			want: &NATSSink{},
+		},
+		{
+			name:    "Unknown sink",
+			env:     map[string]string{"OUTBOX_SINK": "kafka"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			sink, err := NewOutboxSinkFromEnv()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.IsType(t, tt.want, sink)
+		})
+	}
+}
+
+// rejectingSink fails to publish one event and accepts every other
+type rejectingSink struct {
+	rejectID uint
+}
+
+func (s *rejectingSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	if event.ID == s.rejectID {
+		return errors.New("event rejected")
+	}
+	return nil
+}
+
+func TestDispatchPendingGivesUpOnPoisonEvent(t *testing.T) {
+	setupTest(t)
+	ctx := context.Background()
+
+	poison := &models.OutboxEvent{AggregateType: "message", AggregateID: 1, EventType: models.EventMessageSent, Payload: `{}`, Attempts: outboxMaxAttempts - 1}
+	next := &models.OutboxEvent{AggregateType: "message", AggregateID: 1, EventType: models.EventMessageSent, Payload: `{}`}
+	assert.NoError(t, database.DB.Create(poison).Error)
+	assert.NoError(t, database.DB.Create(next).Error)
+	t.Cleanup(func() {
+		database.DB.Delete(&models.OutboxEvent{}, []uint{poison.ID, next.ID})
+	})
+
+	relay := NewOutboxRelay(&rejectingSink{rejectID: poison.ID})
+	// Older events left by other tests come first
+	for i := 0; i < 100; i++ {
+		_, err := relay.dispatchPending(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, database.DB.First(next, next.ID).Error)
+		if next.Dispatched {
+			break
+		}
+	}
+
+	assert.NoError(t, database.DB.First(poison, poison.ID).Error)
+	assert.False(t, poison.Dispatched)
+	assert.NotNil(t, poison.FailedAt)
+	assert.Nil(t, poison.ClaimedUntil)
+	assert.Equal(t, outboxMaxAttempts, poison.Attempts)
+	assert.True(t, next.Dispatched)
+}
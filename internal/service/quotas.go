@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// messageQuotaName names the daily message creation quota in Redis
+const messageQuotaName = "messages"
+
+// QuotaService tracks the daily message creation quota of each API key
+type QuotaService struct{}
+
+func NewQuotaService() *QuotaService {
+	return &QuotaService{}
+}
+
+// ConsumeMessage counts one message created with key against its daily
+// quota. The result is not allowed once the quota is used up.
+func (s *QuotaService) ConsumeMessage(ctx context.Context, key *models.APIKey) (*redis.QuotaResult, error) {
+	limit, err := messageQuota(key)
+	if err != nil {
+		return nil, err
+	}
+	return redis.ConsumeQuota(ctx, messageQuotaName, quotaClient(key), limit, time.Now())
+}
+
+// RefundMessage gives back a unit consumed for a message that was not created
+func (s *QuotaService) RefundMessage(ctx context.Context, key *models.APIKey) error {
+	return redis.RefundQuota(ctx, messageQuotaName, quotaClient(key), time.Now())
+}
+
+// MessageUsage returns how much of its daily message quota key has used
+func (s *QuotaService) MessageUsage(ctx context.Context, key *models.APIKey) (*redis.QuotaResult, error) {
+	limit, err := messageQuota(key)
+	if err != nil {
+		return nil, err
+	}
+	return redis.GetQuota(ctx, messageQuotaName, quotaClient(key), limit, time.Now())
+}
+
+// messageQuota returns a key's daily message quota: its own if set,
+// otherwise API_DAILY_MESSAGE_QUOTA, where 0 means unlimited
+func messageQuota(key *models.APIKey) (int64, error) {
+	if key.DailyMessageQuota > 0 {
+		return key.DailyMessageQuota, nil
+	}
+
+	value := getEnv("API_DAILY_MESSAGE_QUOTA", "10000")
+	quota, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || quota < 0 {
+		return 0, fmt.Errorf("invalid API_DAILY_MESSAGE_QUOTA %q", value)
+	}
+	if quota == 0 {
+		return redis.Unlimited, nil
+	}
+	return quota, nil
+}
+
+// quotaClient identifies an API client in quota and limiter keys
+func quotaClient(key *models.APIKey) string {
+	return strconv.FormatUint(uint64(key.ID), 10)
+}
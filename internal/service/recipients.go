@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/vkukul/messaging-system/pkg/phone"
+)
+
+// normalizeRecipient parses a phone number to E.164, reading numbers in
+// national format as numbers of PHONE_DEFAULT_REGION
+func normalizeRecipient(recipient string) (*phone.Number, error) {
+	region := getEnv("PHONE_DEFAULT_REGION", "")
+	if region != "" && !phone.ValidRegion(region) {
+		return nil, fmt.Errorf("invalid PHONE_DEFAULT_REGION %q", region)
+	}
+	return phone.Parse(recipient, region)
+}
+
+// normalizeEmail validates a bare email address and lower-cases it
+func normalizeEmail(address string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil || parsed.Name != "" || parsed.Address != strings.TrimSpace(address) {
+		return "", fmt.Errorf("invalid email address %q", address)
+	}
+	return strings.ToLower(parsed.Address), nil
+}
+
+// recipientKey is how a recipient is stored and looked up: its E.164 form
+// when it parses as a phone number, lower-cased for email addresses, and as
+// given otherwise so entries made before numbers were normalized can still
+// be found
+func recipientKey(recipient string) string {
+	if number, err := normalizeRecipient(recipient); err == nil {
+		return number.E164
+	}
+	recipient = strings.TrimSpace(recipient)
+	if strings.Contains(recipient, "@") {
+		return strings.ToLower(recipient)
+	}
+	return recipient
+}
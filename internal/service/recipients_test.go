@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/pkg/phone"
+)
+
+func TestNormalizeRecipient(t *testing.T) {
+	t.Setenv("PHONE_DEFAULT_REGION", "")
+	_, err := normalizeRecipient("0532 123 45 67")
+	assert.True(t, errors.Is(err, phone.ErrInvalidNumber))
+
+	t.Setenv("PHONE_DEFAULT_REGION", "TR")
+	number, err := normalizeRecipient("0532 123 45 67")
+	assert.NoError(t, err)
+	assert.Equal(t, "+905321234567", number.E164)
+	assert.Equal(t, "TR", number.Region)
+
+	t.Setenv("PHONE_DEFAULT_REGION", "Turkey")
+	_, err = normalizeRecipient("0532 123 45 67")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, phone.ErrInvalidNumber))
+}
+
+func TestRecipientKey(t *testing.T) {
+	t.Setenv("PHONE_DEFAULT_REGION", "TR")
+	assert.Equal(t, "+905321234567", recipientKey("0532 123 45 67"))
+	assert.Equal(t, "+905321234567", recipientKey("+90 532 123 45 67"))
+	assert.Equal(t, "short-code", recipientKey(" short-code "))
+	assert.Equal(t, "jane@example.com", recipientKey(" Jane@Example.com "))
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{name: "Valid address", address: "Jane.Doe@Example.com", want: "jane.doe@example.com"},
+		{name: "Surrounding spaces", address: " jane@example.com ", want: "jane@example.com"},
+		{name: "Display name", address: "Jane <jane@example.com>", wantErr: true},
+		{name: "Missing domain", address: "jane@", wantErr: true},
+		{name: "Phone number", address: "+905551234567", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeEmail(tt.address)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
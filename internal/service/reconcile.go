@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+const (
+	reconcileInterval  = time.Minute
+	reconcileBatchSize = 50
+	// sendingTimeout is how long a message may stay claimed before its send
+	// is treated as unrecorded; well beyond the gateway request timeout
+	sendingTimeout = 5 * time.Minute
+	// reviewTimeout is how long the reconciler keeps asking a gateway that
+	// cannot answer before leaving the message for review
+	reviewTimeout = time.Hour
+	// reconcileClaimTimeout is how long a replica holds the messages it is
+	// asking gateways about, and how long a message whose gateway could not
+	// answer waits before it is asked about again
+	reconcileClaimTimeout = 5 * time.Minute
+)
+
+// sendOutcome is what a gateway reports about an unrecorded send
+type sendOutcome int
+
+const (
+	sendUnknown sendOutcome = iota
+	sendReceived
+	sendNotReceived
+)
+
+// reconcileOutcomes are the messages_reconciled_total labels of the outcomes
+var reconcileOutcomes = map[sendOutcome]string{
+	sendUnknown:     metrics.ReconcileNeedsReview,
+	sendReceived:    metrics.ReconcileSent,
+	sendNotReceived: metrics.ReconcileNotReceived,
+}
+
+// Reconciler resolves messages stuck in sending: their gateway request was
+// made but its outcome never recorded, e.g. because saving it failed or the
+// replica crashed. Gateways with a status URL are asked whether they got the
+// message; otherwise it is moved to needs_review for an operator.
+type Reconciler struct {
+	client   *http.Client
+	interval time.Duration
+}
+
+func NewReconciler() *Reconciler {
+	return &Reconciler{
+		client:   newHTTPClient(),
+		interval: reconcileInterval,
+	}
+}
+
+// Run resolves stuck messages until ctx is cancelled
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.reconcile(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error reconciling messages", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcile claims a batch of stuck messages, asks their gateways about them
+// without holding any lock and resolves each message on its own
+func (r *Reconciler) reconcile(ctx context.Context) error {
+	messages, err := claimUnrecordedSends(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		msg := &messages[i]
+		gateway, err := claimedGateway(database.DB.WithContext(ctx), msg)
+		if err != nil {
+			messageLogger(msg).ErrorContext(ctx, "Error resolving message route", "error", err)
+			continue
+		}
+
+		outcome, providerMessageID, err := r.lookup(ctx, gateway, msg)
+		if err != nil {
+			if time.Since(*msg.SendingAt) < reviewTimeout {
+				// The claim keeps the message from being asked about again
+				// until it expires
+				messageLogger(msg).WarnContext(ctx, "Could not look up unrecorded send", "error", err)
+				continue
+			}
+			msg.LastError = err.Error()
+		}
+
+		resolved, err := resolveUnrecordedSend(ctx, msg, outcome, providerMessageID)
+		if err != nil {
+			messageLogger(msg).ErrorContext(ctx, "Error reconciling message", "error", err)
+			continue
+		}
+		if !resolved {
+			continue
+		}
+		metrics.MessagesReconciled.WithLabelValues(reconcileOutcomes[outcome]).Inc()
+		if msg.Sent {
+			if err := redis.CacheMessage(ctx, msg); err != nil {
+				messageLogger(msg).WarnContext(ctx, "Failed to cache message", "error", err)
+			}
+		}
+		messageLogger(msg).InfoContext(ctx, "Unrecorded send reconciled", "status", msg.Status)
+	}
+	return nil
+}
+
+// claimUnrecordedSends claims the messages that have been sending for too
+// long by setting their reconcile_after, so other replicas skip them while
+// their gateways are asked and take them over if this one dies. Rows are
+// locked with SKIP LOCKED while claiming.
+func claimUnrecordedSends(ctx context.Context) ([]models.Message, error) {
+	var messages []models.Message
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND sending_at <= ?", models.StatusSending, now.Add(-sendingTimeout)).
+			Where("(reconcile_after IS NULL OR reconcile_after <= ?)", now).
+			Order("sending_at").
+			Limit(reconcileBatchSize).
+			Find(&messages).Error; err != nil {
+			return fmt.Errorf("error fetching unrecorded sends: %v", err)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		if err := tx.Model(&models.Message{}).Where("id IN ?", ids).
+			Update("reconcile_after", now.Add(reconcileClaimTimeout)).Error; err != nil {
+			return fmt.Errorf("error claiming unrecorded sends: %v", err)
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// resolveUnrecordedSend applies a gateway's answer to a claimed message in
+// its own transaction. It reports false when the message was no longer
+// stuck in the same send, e.g. because another replica resolved it.
+func resolveUnrecordedSend(ctx context.Context, msg *models.Message, outcome sendOutcome, providerMessageID string) (bool, error) {
+	resolved := false
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.Message
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ? AND send_token = ?", msg.ID, models.StatusSending, msg.SendToken).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		current.LastError = msg.LastError
+		eventType := resolveSend(&current, outcome, providerMessageID)
+		if err := tx.Save(&current).Error; err != nil {
+			return fmt.Errorf("error updating message %d: %v", current.ID, err)
+		}
+		if err := recordStatusChange(tx, &current, eventType); err != nil {
+			return err
+		}
+		*msg = current
+		resolved = true
+		return nil
+	})
+	return resolved, err
+}
+
+// claimedGateway returns the gateway a message was claimed for. Messages
+// claimed before the provider was recorded went through their tenant's.
+func claimedGateway(tx *gorm.DB, msg *models.Message) (*provider.Config, error) {
+	if msg.Provider != "" {
+		gateway, ok := provider.Get(msg.Provider)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", msg.Provider)
+		}
+		return gateway, nil
+	}
+	route, err := tenantRouteByID(tx, msg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	route, err = route.forChannel(msg.Channel)
+	if err != nil {
+		return nil, err
+	}
+	return route.gateway, nil
+}
+
+// resolveSend applies a gateway's answer about an unrecorded send to msg and
+// returns the event it causes. A message the gateway never received is
+// counted as a failed round and sent again with the same send token.
+func resolveSend(msg *models.Message, outcome sendOutcome, providerMessageID string) string {
+	sentAt := time.Now()
+	if msg.SendingAt != nil {
+		sentAt = *msg.SendingAt
+	}
+	msg.SendingAt = nil
+	msg.ReconcileAfter = nil
+
+	switch outcome {
+	case sendReceived:
+		msg.MessageID = providerMessageID
+		msg.Sent = true
+		msg.SentAt = sentAt
+		msg.Status = models.StatusSent
+		msg.LastError = ""
+		msg.NextAttemptAt = nil
+		return models.EventMessageSent
+	case sendNotReceived:
+		return countFailure(msg, fmt.Errorf("gateway did not receive the message"))
+	default:
+		msg.Status = models.StatusNeedsReview
+		if msg.LastError == "" {
+			msg.LastError = "gateway cannot confirm whether the message was received"
+		}
+		return models.EventMessageNeedsReview
+	}
+}
+
+// lookup asks a gateway whether it received the message sent with msg's
+// send token. Without a status URL the outcome is unknown.
+func (r *Reconciler) lookup(ctx context.Context, gateway *provider.Config, msg *models.Message) (sendOutcome, string, error) {
+	statusURL := gateway.MessageStatusURL(msg.SendToken)
+	if statusURL == "" {
+		return sendUnknown, "", nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", statusURL, nil)
+	if err != nil {
+		return sendUnknown, "", fmt.Errorf("error creating request: %v", err)
+	}
+	gateway.Authorize(req, nil, time.Now())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return sendUnknown, "", fmt.Errorf("error querying gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return sendNotReceived, "", nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return sendReceived, providerMessageID(resp), nil
+	default:
+		return sendUnknown, "", &gatewayStatusError{StatusCode: resp.StatusCode}
+	}
+}
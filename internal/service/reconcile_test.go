@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func TestReconcilerLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantOutcome sendOutcome
+		wantID      string
+		wantErr     bool
+	}{
+		{
+			name:        "Received",
+			status:      http.StatusOK,
+			body:        `{"messageId":"gw-1"}`,
+			wantOutcome: sendReceived,
+			wantID:      "gw-1",
+		},
+		{
+			name:        "Not received",
+			status:      http.StatusNotFound,
+			wantOutcome: sendNotReceived,
+		},
+		{
+			name:        "Gateway error",
+			status:      http.StatusInternalServerError,
+			wantOutcome: sendUnknown,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, token string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				token = r.Header.Get("Authorization")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			gateway := &provider.Config{Name: "primary", URL: server.URL, BearerToken: "secret", StatusURL: server.URL + "/messages/{key}"}
+			msg := &models.Message{ID: 1, SendToken: "send-token"}
+
+			outcome, id, err := NewReconciler().lookup(context.Background(), gateway, msg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantOutcome, outcome)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, "/messages/send-token", path)
+			assert.Equal(t, "Bearer secret", token)
+		})
+	}
+}
+
+func TestReconcilerLookupWithoutStatusURL(t *testing.T) {
+	gateway := &provider.Config{Name: "primary", URL: "https://gateway.example.com/sms"}
+	outcome, _, err := NewReconciler().lookup(context.Background(), gateway, &models.Message{SendToken: "send-token"})
+	assert.NoError(t, err)
+	assert.Equal(t, sendUnknown, outcome)
+}
+
+func TestResolveSend(t *testing.T) {
+	sendingAt := time.Now().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name         string
+		outcome      sendOutcome
+		attempts     int
+		wantStatus   string
+		wantEvent    string
+		wantAttempts int
+	}{
+		{
+			name:         "Received",
+			outcome:      sendReceived,
+			wantStatus:   models.StatusSent,
+			wantEvent:    models.EventMessageSent,
+			wantAttempts: 0,
+		},
+		{
+			name:         "Not received",
+			outcome:      sendNotReceived,
+			wantStatus:   models.StatusFailed,
+			wantEvent:    models.EventMessageFailed,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Not received on last attempt",
+			outcome:      sendNotReceived,
+			attempts:     maxSendAttempts - 1,
+			wantStatus:   models.StatusDeadLettered,
+			wantEvent:    models.EventMessageDeadLettered,
+			wantAttempts: maxSendAttempts,
+		},
+		{
+			name:         "Unknown",
+			outcome:      sendUnknown,
+			wantStatus:   models.StatusNeedsReview,
+			wantEvent:    models.EventMessageNeedsReview,
+			wantAttempts: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := sendingAt
+			claimed := time.Now().Add(reconcileClaimTimeout)
+			msg := &models.Message{Status: models.StatusSending, SendToken: "send-token", SendingAt: &at, ReconcileAfter: &claimed, Attempts: tt.attempts}
+
+			event := resolveSend(msg, tt.outcome, "gw-1")
+			assert.Equal(t, tt.wantEvent, event)
+			assert.Equal(t, tt.wantStatus, msg.Status)
+			assert.Equal(t, tt.wantAttempts, msg.Attempts)
+			assert.Nil(t, msg.SendingAt)
+			assert.Nil(t, msg.ReconcileAfter)
+			assert.Equal(t, "send-token", msg.SendToken)
+			if tt.outcome == sendReceived {
+				assert.True(t, msg.Sent)
+				assert.Equal(t, "gw-1", msg.MessageID)
+				assert.Equal(t, sendingAt, msg.SentAt)
+			} else {
+				assert.False(t, msg.Sent)
+				assert.NotEmpty(t, msg.LastError)
+			}
+		})
+	}
+}
+
+func TestClaimUnrecordedSends(t *testing.T) {
+	setupTest(t)
+	ctx := context.Background()
+
+	sendingAt := time.Now().Add(-2 * sendingTimeout)
+	msg := &models.Message{To: "+905551234567", Content: "Stuck", Status: models.StatusSending, SendToken: "stuck-send-token", SendingAt: &sendingAt}
+	assert.NoError(t, database.DB.Create(msg).Error)
+	defer database.DB.Unscoped().Delete(msg)
+
+	claimed, err := claimUnrecordedSends(ctx)
+	assert.NoError(t, err)
+	assert.True(t, containsMessage(claimed, msg.ID))
+
+	// A claimed message is left to the replica holding it
+	claimed, err = claimUnrecordedSends(ctx)
+	assert.NoError(t, err)
+	assert.False(t, containsMessage(claimed, msg.ID))
+
+	resolved, err := resolveUnrecordedSend(ctx, msg, sendReceived, "gw-stuck")
+	assert.NoError(t, err)
+	assert.True(t, resolved)
+	defer database.DB.Where("aggregate_id = ?", msg.ID).Delete(&models.OutboxEvent{})
+
+	var stored models.Message
+	assert.NoError(t, database.DB.First(&stored, msg.ID).Error)
+	assert.Equal(t, models.StatusSent, stored.Status)
+	assert.Equal(t, "gw-stuck", stored.MessageID)
+	assert.Nil(t, stored.ReconcileAfter)
+
+	// Resolving it again, e.g. from another replica, changes nothing
+	resolved, err = resolveUnrecordedSend(ctx, msg, sendNotReceived, "")
+	assert.NoError(t, err)
+	assert.False(t, resolved)
+}
+
+func containsMessage(messages []models.Message, id uint) bool {
+	for _, msg := range messages {
+		if msg.ID == id {
+			return true
+		}
+	}
+	return false
+}
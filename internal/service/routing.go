@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+)
+
+// routeProviders returns the gateways to try for a message, best first. A
+// tenant's own provider always comes first; the others serving the
+// message's channel and recipient follow, those whose circuit breaker lets
+// sends through before those it holds back and cheapest first, in
+// configuration order on ties.
+func routeProviders(route *sendRoute, msg *models.Message) ([]*provider.Config, error) {
+	channel := msg.Channel
+	if channel == "" {
+		channel = models.ChannelSMS
+	}
+
+	var gateways []*provider.Config
+	if route.pinned && route.gateway.ServesChannel(channel) {
+		gateways = append(gateways, route.gateway)
+	}
+
+	type candidate struct {
+		gateway   *provider.Config
+		cost      float64
+		available bool
+	}
+	var candidates []candidate
+	for _, gateway := range provider.Serving(channel) {
+		if len(gateways) > 0 && gateway == gateways[0] {
+			continue
+		}
+		cost, ok := gateway.Cost(msg.To)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{gateway: gateway, cost: cost, available: breakers.available(gateway.Name)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].available != candidates[j].available {
+			return candidates[i].available
+		}
+		return candidates[i].cost < candidates[j].cost
+	})
+	for _, c := range candidates {
+		gateways = append(gateways, c.gateway)
+	}
+
+	if len(gateways) == 0 {
+		return nil, fmt.Errorf("no provider serves %s messages to %s", channel, msg.To)
+	}
+	return gateways, nil
+}
+
+// failOverStatuses are the gateway answers showing a request was turned
+// away before the message was taken: rate limiting, and what proxies and
+// load balancers answer when the gateway behind them is unavailable
+var failOverStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// canFailOver reports whether a send error shows the gateway did not take
+// the message, so that it can be sent through another provider without
+// risking a duplicate: it refused the connection or answered 429, 502, 503
+// or 504. Other 5xx answers may come after the message was taken, so they
+// are retried on the same provider like timeouts, whose idempotency key
+// drops the repeat if the first request got through.
+func canFailOver(err error) bool {
+	var status *gatewayStatusError
+	if errors.As(err, &status) {
+		return failOverStatuses[status.StatusCode]
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// recordAttempt stores the outcome of one gateway request for a message
+func recordAttempt(tx *gorm.DB, msg *models.Message, gateway *provider.Config, start time.Time, sendErr error) error {
+	attempt := &models.DeliveryAttempt{
+		MessageID:  msg.ID,
+		TenantID:   msg.TenantID,
+		Provider:   gateway.Name,
+		Status:     models.AttemptSent,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if cost, ok := gateway.Cost(msg.To); ok && !math.IsInf(cost, 1) {
+		segments := msg.Segments
+		if segments < 1 {
+			segments = 1
+		}
+		total := cost * float64(segments)
+		attempt.Cost = &total
+	}
+	if sendErr != nil {
+		attempt.Status = models.AttemptFailed
+		attempt.Error = sendErr.Error()
+		attempt.ErrorClass = errorClass(sendErr)
+	}
+	if err := tx.Create(attempt).Error; err != nil {
+		return fmt.Errorf("error recording delivery attempt: %v", err)
+	}
+	return nil
+}
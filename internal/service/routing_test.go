@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+func useProviders(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("PROVIDERS_FILE", path)
+	assert.NoError(t, provider.Init())
+	t.Cleanup(func() {
+		os.Setenv("PROVIDERS_FILE", "")
+		provider.Init()
+	})
+}
+
+func TestRouteProviders(t *testing.T) {
+	useProviders(t, `[
+		{"name":"flat","url":"https://flat.example.com"},
+		{"name":"global","url":"https://global.example.com","costs":{"*":0.05}},
+		{"name":"turkey","url":"https://tr.example.com","costs":{"90":0.02,"9053":0.01}},
+		{"name":"mail","channel":"email","url":"https://mail.example.com"}
+	]`)
+	useBreakers(t, defaultBreakerSettings)
+
+	names := func(gateways []*provider.Config) []string {
+		var names []string
+		for _, gateway := range gateways {
+			names = append(names, gateway.Name)
+		}
+		return names
+	}
+	msg := &models.Message{To: "+905331234567"}
+
+	gateways, err := routeProviders(defaultRoute(), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"turkey", "global", "flat"}, names(gateways))
+
+	gateways, err = routeProviders(defaultRoute(), &models.Message{To: "+14155550100"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"global", "flat"}, names(gateways))
+
+	pinned, _ := provider.Get("flat")
+	gateways, err = routeProviders(&sendRoute{gateway: pinned, pinned: true}, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"flat", "turkey", "global"}, names(gateways))
+
+	for i := 0; i < defaultBreakerSettings.failures; i++ {
+		breakers.record("turkey", &gatewayStatusError{StatusCode: 503})
+	}
+	gateways, err = routeProviders(defaultRoute(), msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"global", "flat", "turkey"}, names(gateways))
+
+	gateways, err = routeProviders(defaultRoute(), &models.Message{Channel: models.ChannelEmail, To: "jane@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mail"}, names(gateways))
+
+	_, err = routeProviders(defaultRoute(), &models.Message{Channel: models.ChannelPush, To: "token"})
+	assert.Error(t, err)
+}
+
+func TestCanFailOver(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Rate limited", err: &gatewayStatusError{StatusCode: 429}, want: true},
+		{name: "Bad gateway", err: &gatewayStatusError{StatusCode: 502}, want: true},
+		{name: "Unavailable", err: &gatewayStatusError{StatusCode: 503}, want: true},
+		{name: "Gateway timeout", err: &gatewayStatusError{StatusCode: 504}, want: true},
+		{name: "Internal server error", err: &gatewayStatusError{StatusCode: 500}, want: false},
+		{name: "Not implemented", err: &gatewayStatusError{StatusCode: 501}, want: false},
+		{name: "Client error", err: &gatewayStatusError{StatusCode: 400}, want: false},
+		{name: "Connection refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "Read failed", err: &net.OpError{Op: "read", Err: errors.New("connection reset")}, want: false},
+		{name: "Other error", err: errors.New("error marshaling JSON"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, canFailOver(tt.err))
+		})
+	}
+}
+
+func TestSendMessageWithRetryKeepsProviderOnServerError(t *testing.T) {
+	setupTest(t)
+	setupLocalRedis(t)
+	service := NewMessageService()
+	ctx := context.Background()
+
+	var first, second int
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	spare := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		second++
+		w.Write([]byte(`{"messageId":"spare-1"}`))
+	}))
+	defer spare.Close()
+	useProviders(t, `[
+		{"name":"cheap","url":"`+failing.URL+`","costs":{"*":0.01}},
+		{"name":"spare","url":"`+spare.URL+`","costs":{"*":0.02}}
+	]`)
+	useBreakers(t, defaultBreakerSettings)
+
+	msg := &models.Message{TenantID: models.DefaultTenantID, To: "+905556666666", Content: "Maybe taken", Status: models.StatusPending}
+	assert.NoError(t, database.DB.Create(msg).Error)
+
+	// A 500 may come after the gateway took the message, so it is only
+	// retried on the same provider
+	assert.Error(t, service.sendMessageWithRetry(ctx, msg, defaultRoute()))
+	assert.Equal(t, maxRetries, first)
+	assert.Zero(t, second)
+
+	// Clean up
+	database.DB.Where("message_id = ?", msg.ID).Delete(&models.DeliveryAttempt{})
+	database.DB.Unscoped().Delete(msg)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+// unlimitedQuota marks a tenant without a daily quota in quota maps
+const unlimitedQuota = -1
+
+// selectBatch picks the next messages to send. Every active tenant with
+// quota left gets a turn in rotation, so a tenant with a large backlog
+// cannot hold back the others. The quota counts messages sent since the
+// start of the UTC day; replicas check it independently, so concurrent
+// batches may overshoot it by at most one batch each.
+func (s *MessageService) selectBatch(ctx context.Context) ([]models.Message, map[uint]*models.Tenant, error) {
+	var tenants []models.Tenant
+	if err := database.DB.WithContext(ctx).Where("active = ?", true).Find(&tenants).Error; err != nil {
+		return nil, nil, fmt.Errorf("error fetching tenants: %v", err)
+	}
+
+	var sentToday []struct {
+		TenantID uint
+		Count    int
+	}
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	if err := database.DB.WithContext(ctx).Model(&models.Message{}).
+		Select("tenant_id, COUNT(*) AS count").
+		Where("sent = ? AND sent_at >= ?", true, dayStart).
+		Group("tenant_id").
+		Scan(&sentToday).Error; err != nil {
+		return nil, nil, fmt.Errorf("error counting sent messages: %v", err)
+	}
+	sent := make(map[uint]int, len(sentToday))
+	for _, row := range sentToday {
+		sent[row.TenantID] = row.Count
+	}
+
+	byID := make(map[uint]*models.Tenant, len(tenants))
+	remaining := make(map[uint]int, len(tenants))
+	var eligible []uint
+	for i := range tenants {
+		tenant := &tenants[i]
+		byID[tenant.ID] = tenant
+		quota := tenantQuota(tenant, sent[tenant.ID])
+		if quota == 0 {
+			continue
+		}
+		remaining[tenant.ID] = quota
+		eligible = append(eligible, tenant.ID)
+	}
+	if len(eligible) == 0 {
+		return nil, byID, nil
+	}
+
+	// At most batchSize due messages per tenant, oldest first
+	var candidates []models.Message
+	if err := database.DB.WithContext(ctx).Raw(`
+		SELECT * FROM (
+			SELECT messages.*, ROW_NUMBER() OVER (PARTITION BY tenant_id ORDER BY id) AS tenant_rank
+			FROM messages
+			WHERE status IN ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) AND tenant_id IN ?
+		) ranked
+		WHERE tenant_rank <= ?
+		ORDER BY id`,
+		[]string{models.StatusPending, models.StatusFailed}, time.Now(), eligible, batchSize).
+		Scan(&candidates).Error; err != nil {
+		return nil, nil, fmt.Errorf("error fetching messages: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := interleaveTenants(candidates, remaining, s.nextTenant, batchSize)
+	if len(batch) > 0 {
+		// The next batch starts with the tenant after the one served first
+		s.nextTenant = batch[0].TenantID + 1
+	}
+	return batch, byID, nil
+}
+
+// tenantQuota returns how many more messages a tenant may send today, or
+// unlimitedQuota
+func tenantQuota(tenant *models.Tenant, sentToday int) int {
+	if tenant.DailyQuota <= 0 {
+		return unlimitedQuota
+	}
+	if sentToday >= tenant.DailyQuota {
+		return 0
+	}
+	return tenant.DailyQuota - sentToday
+}
+
+// interleaveTenants takes up to n messages, one per tenant in turn, starting
+// with the first tenant whose ID is at least start and wrapping around.
+// Messages keep their order within a tenant, and no tenant gets more than
+// its remaining quota. Tenants missing from remaining are skipped.
+func interleaveTenants(messages []models.Message, remaining map[uint]int, start uint, n int) []models.Message {
+	queues := make(map[uint][]models.Message)
+	for _, msg := range messages {
+		quota, ok := remaining[msg.TenantID]
+		if !ok {
+			continue
+		}
+		if quota != unlimitedQuota && len(queues[msg.TenantID]) >= quota {
+			continue
+		}
+		queues[msg.TenantID] = append(queues[msg.TenantID], msg)
+	}
+
+	order := make([]uint, 0, len(queues))
+	for tenantID := range queues {
+		order = append(order, tenantID)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	first := sort.Search(len(order), func(i int) bool { return order[i] >= start })
+	order = append(order[first:], order[:first]...)
+
+	batch := make([]models.Message, 0, n)
+	for len(batch) < n {
+		progressed := false
+		for _, tenantID := range order {
+			if len(batch) == n {
+				break
+			}
+			if queue := queues[tenantID]; len(queue) > 0 {
+				batch = append(batch, queue[0])
+				queues[tenantID] = queue[1:]
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return batch
+}
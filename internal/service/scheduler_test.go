@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+func TestInterleaveTenants(t *testing.T) {
+	messages := []models.Message{
+		{ID: 1, TenantID: 1},
+		{ID: 2, TenantID: 1},
+		{ID: 3, TenantID: 1},
+		{ID: 4, TenantID: 2},
+		{ID: 5, TenantID: 3},
+		{ID: 6, TenantID: 3},
+	}
+
+	tests := []struct {
+		name      string
+		remaining map[uint]int
+		start     uint
+		n         int
+		wantIDs   []uint
+	}{
+		{
+			name:      "round robin",
+			remaining: map[uint]int{1: unlimitedQuota, 2: unlimitedQuota, 3: unlimitedQuota},
+			start:     0,
+			n:         6,
+			wantIDs:   []uint{1, 4, 5, 2, 6, 3},
+		},
+		{
+			name:      "rotation start",
+			remaining: map[uint]int{1: unlimitedQuota, 2: unlimitedQuota, 3: unlimitedQuota},
+			start:     2,
+			n:         2,
+			wantIDs:   []uint{4, 5},
+		},
+		{
+			name:      "rotation wraps around",
+			remaining: map[uint]int{1: unlimitedQuota, 2: unlimitedQuota, 3: unlimitedQuota},
+			start:     4,
+			n:         3,
+			wantIDs:   []uint{1, 4, 5},
+		},
+		{
+			name:      "quota caps a tenant",
+			remaining: map[uint]int{1: 1, 3: unlimitedQuota},
+			start:     0,
+			n:         4,
+			wantIDs:   []uint{1, 5, 6},
+		},
+		{
+			name:      "no eligible tenants",
+			remaining: map[uint]int{},
+			start:     0,
+			n:         2,
+			wantIDs:   []uint{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := interleaveTenants(messages, tt.remaining, tt.start, tt.n)
+			ids := make([]uint, 0, len(batch))
+			for _, msg := range batch {
+				ids = append(ids, msg.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		quota     int
+		sentToday int
+		want      int
+	}{
+		{name: "unlimited", quota: 0, sentToday: 1000, want: unlimitedQuota},
+		{name: "remaining", quota: 100, sentToday: 40, want: 60},
+		{name: "exhausted", quota: 100, sentToday: 100, want: 0},
+		{name: "overshot", quota: 100, sentToday: 103, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tenantQuota(&models.Tenant{DailyQuota: tt.quota}, tt.sentToday))
+		})
+	}
+}
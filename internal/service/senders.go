@@ -0,0 +1,229 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/phone"
+	"github.com/vkukul/messaging-system/pkg/sms"
+)
+
+const (
+	maxSubjectLength   = 998
+	maxAttachments     = 10
+	maxAttachmentBytes = 1 << 20
+	maxDeviceToken     = 4096
+	// maxPushPayload is the largest notification APNs and FCM both accept
+	maxPushPayload = 4096
+)
+
+// sender validates and sends the messages of one channel
+type sender interface {
+	// validate checks a new message and fills in the fields derived from it
+	validate(msg *models.Message) error
+	// send posts a claimed message to the gateway and returns the gateway's
+	// ID for it
+	send(ctx context.Context, client *http.Client, gateway *provider.Config, msg *models.Message) (string, error)
+}
+
+var senders = map[string]sender{
+	models.ChannelSMS:   smsSender{},
+	models.ChannelEmail: emailSender{},
+	models.ChannelPush:  pushSender{},
+}
+
+// senderFor returns the sender of a channel. Messages stored before channels
+// existed have none and are SMS.
+func senderFor(channel string) (sender, error) {
+	if channel == "" {
+		channel = models.ChannelSMS
+	}
+	s, ok := senders[channel]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidMessage, channel)
+	}
+	return s, nil
+}
+
+// smsSender sends text messages to phone numbers
+type smsSender struct{}
+
+func (smsSender) validate(msg *models.Message) error {
+	if msg.Subject != "" || msg.HTMLBody != "" || len(msg.Attachments) > 0 || msg.Title != "" || len(msg.Data) > 0 {
+		return fmt.Errorf("%w: subject, html_body, attachments, title and data do not apply to SMS", ErrInvalidMessage)
+	}
+	number, err := normalizeRecipient(msg.To)
+	if errors.Is(err, phone.ErrInvalidNumber) {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if err != nil {
+		return err
+	}
+	if msg.Content == "" {
+		return fmt.Errorf("%w: content cannot be empty", ErrInvalidMessage)
+	}
+	limit, err := maxSegments()
+	if err != nil {
+		return err
+	}
+	info := sms.Analyze(msg.Content)
+	if info.Segments > limit {
+		return fmt.Errorf("%w: content needs %d %s segments, more than the maximum of %d", ErrInvalidMessage, info.Segments, info.Encoding, limit)
+	}
+
+	msg.To = number.E164
+	msg.CountryCode = number.CountryCode
+	msg.Region = number.Region
+	return nil
+}
+
+func (smsSender) send(ctx context.Context, client *http.Client, gateway *provider.Config, msg *models.Message) (string, error) {
+	return postToGateway(ctx, client, gateway, msg, map[string]string{
+		"to":      msg.To,
+		"content": msg.Content,
+	})
+}
+
+// emailSender sends emails with a plain text and/or HTML body
+type emailSender struct{}
+
+func (emailSender) validate(msg *models.Message) error {
+	if msg.Title != "" || len(msg.Data) > 0 {
+		return fmt.Errorf("%w: title and data do not apply to email", ErrInvalidMessage)
+	}
+	address, err := normalizeEmail(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if strings.TrimSpace(msg.Subject) == "" {
+		return fmt.Errorf("%w: subject cannot be empty", ErrInvalidMessage)
+	}
+	if len(msg.Subject) > maxSubjectLength || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject must be a single line of at most %d characters", ErrInvalidMessage, maxSubjectLength)
+	}
+	if msg.Content == "" && msg.HTMLBody == "" {
+		return fmt.Errorf("%w: content or html_body is required", ErrInvalidMessage)
+	}
+	if len(msg.Attachments) > maxAttachments {
+		return fmt.Errorf("%w: at most %d attachments are allowed", ErrInvalidMessage, maxAttachments)
+	}
+	size := 0
+	for i := range msg.Attachments {
+		attachment := &msg.Attachments[i]
+		if attachment.Filename == "" {
+			return fmt.Errorf("%w: attachment %d has no filename", ErrInvalidMessage, i+1)
+		}
+		data, err := base64.StdEncoding.DecodeString(attachment.Content)
+		if err != nil {
+			return fmt.Errorf("%w: attachment %s is not valid base64", ErrInvalidMessage, attachment.Filename)
+		}
+		size += len(data)
+		if attachment.ContentType == "" {
+			attachment.ContentType = http.DetectContentType(data)
+		}
+	}
+	if size > maxAttachmentBytes {
+		return fmt.Errorf("%w: attachments are larger than %d bytes", ErrInvalidMessage, maxAttachmentBytes)
+	}
+
+	msg.To = address
+	return nil
+}
+
+func (emailSender) send(ctx context.Context, client *http.Client, gateway *provider.Config, msg *models.Message) (string, error) {
+	return postToGateway(ctx, client, gateway, msg, struct {
+		To          string              `json:"to"`
+		Subject     string              `json:"subject"`
+		Text        string              `json:"text,omitempty"`
+		HTML        string              `json:"html,omitempty"`
+		Attachments []models.Attachment `json:"attachments,omitempty"`
+	}{
+		To:          msg.To,
+		Subject:     msg.Subject,
+		Text:        msg.Content,
+		HTML:        msg.HTMLBody,
+		Attachments: msg.Attachments,
+	})
+}
+
+// pushSender sends notifications to device tokens
+type pushSender struct{}
+
+// pushPayload is the notification posted to a push gateway
+type pushPayload struct {
+	To    string            `json:"to"`
+	Title string            `json:"title,omitempty"`
+	Body  string            `json:"body,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+func (pushSender) validate(msg *models.Message) error {
+	if msg.Subject != "" || msg.HTMLBody != "" || len(msg.Attachments) > 0 {
+		return fmt.Errorf("%w: subject, html_body and attachments do not apply to push", ErrInvalidMessage)
+	}
+	msg.To = strings.TrimSpace(msg.To)
+	if len(msg.To) > maxDeviceToken || strings.ContainsAny(msg.To, " \t\r\n") {
+		return fmt.Errorf("%w: recipient must be a device token", ErrInvalidMessage)
+	}
+	if msg.Title == "" && msg.Content == "" {
+		return fmt.Errorf("%w: title or content is required", ErrInvalidMessage)
+	}
+	data, err := json.Marshal(pushPayload{Title: msg.Title, Body: msg.Content, Data: msg.Data})
+	if err != nil {
+		return fmt.Errorf("error marshaling push payload: %v", err)
+	}
+	if len(data) > maxPushPayload {
+		return fmt.Errorf("%w: title, content and data are larger than %d bytes", ErrInvalidMessage, maxPushPayload)
+	}
+	return nil
+}
+
+func (pushSender) send(ctx context.Context, client *http.Client, gateway *provider.Config, msg *models.Message) (string, error) {
+	return postToGateway(ctx, client, gateway, msg, pushPayload{
+		To:    msg.To,
+		Title: msg.Title,
+		Body:  msg.Content,
+		Data:  msg.Data,
+	})
+}
+
+// postToGateway posts a payload to a gateway with the message's send token
+// as idempotency key and returns the gateway's ID for the message
+func postToGateway(ctx context.Context, client *http.Client, gateway *provider.Config, msg *models.Message, payload interface{}) (string, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling JSON: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", gateway.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(provider.IdempotencyHeader, msg.SendToken)
+	gateway.Authorize(req, jsonData, time.Now())
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	observeWebhook(metrics.DestinationProvider, start, resp, err)
+	if err != nil {
+		return "", fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", &gatewayStatusError{StatusCode: resp.StatusCode}
+	}
+	return providerMessageID(resp), nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+func TestSenderFor(t *testing.T) {
+	s, err := senderFor("")
+	assert.NoError(t, err)
+	assert.IsType(t, smsSender{}, s)
+
+	s, err = senderFor(models.ChannelPush)
+	assert.NoError(t, err)
+	assert.IsType(t, pushSender{}, s)
+
+	_, err = senderFor("fax")
+	assert.True(t, errors.Is(err, ErrInvalidMessage))
+}
+
+func TestSenderValidate(t *testing.T) {
+	t.Setenv("PHONE_DEFAULT_REGION", "")
+	t.Setenv("MESSAGE_MAX_SEGMENTS", "3")
+
+	tests := []struct {
+		name    string
+		msg     models.Message
+		wantTo  string
+		wantErr bool
+	}{
+		{
+			name:   "SMS",
+			msg:    models.Message{Channel: models.ChannelSMS, To: "+90 555 123 45 67", Content: "Hi"},
+			wantTo: "+905551234567",
+		},
+		{
+			name:    "SMS with subject",
+			msg:     models.Message{Channel: models.ChannelSMS, To: "+905551234567", Content: "Hi", Subject: "Hi"},
+			wantErr: true,
+		},
+		{
+			name:   "Email with text body",
+			msg:    models.Message{Channel: models.ChannelEmail, To: "Jane@Example.com", Subject: "Hi", Content: "Hello"},
+			wantTo: "jane@example.com",
+		},
+		{
+			name:   "Email with HTML body and attachment",
+			msg:    models.Message{Channel: models.ChannelEmail, To: "jane@example.com", Subject: "Hi", HTMLBody: "<p>Hello</p>", Attachments: []models.Attachment{{Filename: "a.txt", Content: "aGVsbG8="}}},
+			wantTo: "jane@example.com",
+		},
+		{
+			name:    "Email without subject",
+			msg:     models.Message{Channel: models.ChannelEmail, To: "jane@example.com", Content: "Hello"},
+			wantErr: true,
+		},
+		{
+			name:    "Email without body",
+			msg:     models.Message{Channel: models.ChannelEmail, To: "jane@example.com", Subject: "Hi"},
+			wantErr: true,
+		},
+		{
+			name:    "Email to phone number",
+			msg:     models.Message{Channel: models.ChannelEmail, To: "+905551234567", Subject: "Hi", Content: "Hello"},
+			wantErr: true,
+		},
+		{
+			name:    "Attachment not base64",
+			msg:     models.Message{Channel: models.ChannelEmail, To: "jane@example.com", Subject: "Hi", Content: "Hello", Attachments: []models.Attachment{{Filename: "a.txt", Content: "not base64!"}}},
+			wantErr: true,
+		},
+		{
+			name:   "Push",
+			msg:    models.Message{Channel: models.ChannelPush, To: "device-token", Title: "Hi", Data: map[string]string{"order": "42"}},
+			wantTo: "device-token",
+		},
+		{
+			name:    "Push without title or content",
+			msg:     models.Message{Channel: models.ChannelPush, To: "device-token"},
+			wantErr: true,
+		},
+		{
+			name:    "Push payload too large",
+			msg:     models.Message{Channel: models.ChannelPush, To: "device-token", Content: strings.Repeat("a", maxPushPayload)},
+			wantErr: true,
+		},
+		{
+			name:    "Push with subject",
+			msg:     models.Message{Channel: models.ChannelPush, To: "device-token", Title: "Hi", Subject: "Hi"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := senderFor(tt.msg.Channel)
+			assert.NoError(t, err)
+
+			msg := tt.msg
+			err = s.validate(&msg)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidMessage))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTo, msg.To)
+		})
+	}
+}
+
+func TestAttachmentContentType(t *testing.T) {
+	msg := &models.Message{
+		Channel:     models.ChannelEmail,
+		To:          "jane@example.com",
+		Subject:     "Hi",
+		Content:     "Hello",
+		Attachments: []models.Attachment{{Filename: "a.txt", Content: "aGVsbG8="}},
+	}
+	assert.NoError(t, emailSender{}.validate(msg))
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Attachments[0].ContentType)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/logging"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/phone"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+var (
+	// ErrInvalidSuppression is returned when a suppression fails validation
+	ErrInvalidSuppression = errors.New("invalid suppression")
+	// ErrSuppressionNotFound is returned when a recipient is not on the list
+	ErrSuppressionNotFound = errors.New("suppression not found")
+)
+
+var suppressionReasons = map[string]bool{
+	models.SuppressionReasonOptOut:    true,
+	models.SuppressionReasonComplaint: true,
+	models.SuppressionReasonBounce:    true,
+	models.SuppressionReasonManual:    true,
+}
+
+// SuppressionService manages the lists of recipients each tenant must not
+// message
+type SuppressionService struct{}
+
+func NewSuppressionService() *SuppressionService {
+	return &SuppressionService{}
+}
+
+// Suppress adds a phone number or email address to a tenant's list,
+// replacing any existing entry. A nil expiresAt suppresses the recipient
+// indefinitely.
+func (s *SuppressionService) Suppress(tenantID uint, recipient, reason, note string, expiresAt *time.Time) (*models.Suppression, error) {
+	if strings.TrimSpace(recipient) == "" {
+		return nil, fmt.Errorf("%w: recipient cannot be empty", ErrInvalidSuppression)
+	}
+	if strings.Contains(recipient, "@") {
+		address, err := normalizeEmail(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSuppression, err)
+		}
+		recipient = address
+	} else {
+		number, err := normalizeRecipient(recipient)
+		if errors.Is(err, phone.ErrInvalidNumber) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSuppression, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		recipient = number.E164
+	}
+	if !suppressionReasons[reason] {
+		return nil, fmt.Errorf("%w: unknown reason %q", ErrInvalidSuppression, reason)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidSuppression)
+	}
+
+	sup := &models.Suppression{
+		TenantID:  tenantID,
+		Recipient: recipient,
+		Reason:    reason,
+		Note:      note,
+		ExpiresAt: expiresAt,
+	}
+	if err := upsertSuppression(database.DB, sup); err != nil {
+		return nil, fmt.Errorf("error saving suppression: %v", err)
+	}
+	cacheSuppression(context.Background(), sup)
+	return sup, nil
+}
+
+func (s *SuppressionService) List(tenantID uint) ([]models.Suppression, error) {
+	var sups []models.Suppression
+	if err := database.DB.Where("tenant_id = ?", tenantID).Order("id desc").Find(&sups).Error; err != nil {
+		return nil, fmt.Errorf("error fetching suppressions: %v", err)
+	}
+	return sups, nil
+}
+
+func (s *SuppressionService) Get(tenantID uint, recipient string) (*models.Suppression, error) {
+	var sup models.Suppression
+	if err := database.DB.Where("tenant_id = ? AND recipient = ?", tenantID, recipientKey(recipient)).First(&sup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSuppressionNotFound
+		}
+		return nil, fmt.Errorf("error fetching suppression: %v", err)
+	}
+	return &sup, nil
+}
+
+// Remove takes a recipient off a tenant's list so the tenant's messages to it
+// are sent again
+func (s *SuppressionService) Remove(tenantID uint, recipient string) error {
+	recipient = recipientKey(recipient)
+	result := database.DB.Where("tenant_id = ? AND recipient = ?", tenantID, recipient).Delete(&models.Suppression{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting suppression: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSuppressionNotFound
+	}
+
+	if err := redis.ClearSuppression(context.Background(), tenantID, recipient); err != nil {
+		slog.Warn("Failed to clear cached suppression", logging.Recipient(recipient), "error", err)
+	}
+	return nil
+}
+
+// upsertSuppression inserts or replaces a tenant's suppression for a recipient
+func upsertSuppression(tx *gorm.DB, sup *models.Suppression) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "recipient"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "note", "expires_at", "updated_at"}),
+	}).Create(sup).Error
+}
+
+// cacheSuppression marks a recipient suppressed for a tenant in Redis until
+// the entry expires. Failures only cost a database lookup on the next check.
+func cacheSuppression(ctx context.Context, sup *models.Suppression) {
+	ttl := redis.SuppressionCacheTTL
+	if sup.ExpiresAt != nil {
+		ttl = time.Until(*sup.ExpiresAt)
+	}
+	if err := redis.CacheSuppression(ctx, sup.TenantID, sup.Recipient, true, ttl); err != nil {
+		slog.WarnContext(ctx, "Failed to cache suppression", logging.Recipient(sup.Recipient), "error", err)
+	}
+}
+
+// isSuppressed checks whether a tenant has suppressed a recipient, using the
+// Redis cache and falling back to the database
+func isSuppressed(ctx context.Context, tenantID uint, recipient string) (bool, error) {
+	suppressed, found, err := redis.GetCachedSuppression(ctx, tenantID, recipient)
+	if err != nil {
+		slog.WarnContext(ctx, "Suppression cache lookup failed", logging.Recipient(recipient), "error", err)
+	} else if found {
+		return suppressed, nil
+	}
+
+	var sup models.Suppression
+	err = database.DB.WithContext(ctx).Where("tenant_id = ? AND recipient = ?", tenantID, recipient).First(&sup).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("error checking suppression list: %v", err)
+	}
+
+	if err == nil && sup.Active(time.Now()) {
+		cacheSuppression(ctx, &sup)
+		return true, nil
+	}
+	if err := redis.CacheSuppression(ctx, tenantID, recipient, false, redis.SuppressionCacheTTL); err != nil {
+		slog.WarnContext(ctx, "Failed to cache suppression", logging.Recipient(recipient), "error", err)
+	}
+	return false, nil
+}
+
+// suppressHandler adds the sender of a keyword to the suppression list of
+// every tenant that has messaged them through the gateway the keyword came
+// in on. Tenants can share a sender number, so the reply cannot tell which
+// of them the sender meant.
+func suppressHandler(reason string) KeywordHandler {
+	return func(tx *gorm.DB, inbound *models.InboundMessage) error {
+		tenantIDs, err := senderTenants(tx, inbound)
+		if err != nil {
+			return err
+		}
+		for _, tenantID := range tenantIDs {
+			sup := &models.Suppression{
+				TenantID:  tenantID,
+				Recipient: inbound.From,
+				Reason:    reason,
+				Note:      "keyword " + inbound.Keyword,
+			}
+			if err := upsertSuppression(tx, sup); err != nil {
+				return err
+			}
+			// Caching before commit errs on the side of not sending
+			cacheSuppression(context.Background(), sup)
+		}
+		return nil
+	}
+}
+
+// senderTenants returns the tenant an inbound message belongs to and every
+// other tenant with messages to its sender through the same gateway,
+// including messages sent before the gateway was recorded
+func senderTenants(tx *gorm.DB, inbound *models.InboundMessage) ([]uint, error) {
+	var tenantIDs []uint
+	if err := tx.Model(&models.Message{}).
+		Where(`"to" = ? AND (provider = ? OR provider IS NULL OR provider = '')`, inbound.From, inbound.Provider).
+		Distinct().
+		Pluck("tenant_id", &tenantIDs).Error; err != nil {
+		return nil, fmt.Errorf("error finding tenants messaging %s: %v", inbound.From, err)
+	}
+	for _, id := range tenantIDs {
+		if id == inbound.TenantID {
+			return tenantIDs, nil
+		}
+	}
+	return append(tenantIDs, inbound.TenantID), nil
+}
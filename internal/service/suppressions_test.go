@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+func TestSuppressValidation(t *testing.T) {
+	service := NewSuppressionService()
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		recipient string
+		reason    string
+		expiresAt *time.Time
+	}{
+		{
+			name:      "Empty recipient",
+			recipient: " ",
+			reason:    models.SuppressionReasonManual,
+		},
+		{
+			name:      "Invalid recipient",
+			recipient: "+90555",
+			reason:    models.SuppressionReasonManual,
+		},
+		{
+			name:      "Invalid email address",
+			recipient: "jane@",
+			reason:    models.SuppressionReasonManual,
+		},
+		{
+			name:      "Unknown reason",
+			recipient: "+905551234567",
+			reason:    "annoyed",
+		},
+		{
+			name:      "Expiry in the past",
+			recipient: "+905551234567",
+			reason:    models.SuppressionReasonManual,
+			expiresAt: &past,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Suppress(models.DefaultTenantID, tt.recipient, tt.reason, "", tt.expiresAt)
+			assert.ErrorIs(t, err, ErrInvalidSuppression)
+		})
+	}
+}
+
+func TestIsSuppressed(t *testing.T) {
+	setupTest(t)
+	service := NewSuppressionService()
+	ctx := context.Background()
+	tenant := models.DefaultTenantID
+	recipient := "+905557654321"
+
+	assert.NoError(t, redis.ClearSuppression(ctx, tenant, recipient))
+	suppressed, err := isSuppressed(ctx, tenant, recipient)
+	assert.NoError(t, err)
+	assert.False(t, suppressed)
+
+	// Suppressing overrides the cached negative answer
+	_, err = service.Suppress(tenant, recipient, models.SuppressionReasonOptOut, "", nil)
+	assert.NoError(t, err)
+	suppressed, err = isSuppressed(ctx, tenant, recipient)
+	assert.NoError(t, err)
+	assert.True(t, suppressed)
+
+	// Removing clears the cache
+	assert.NoError(t, service.Remove(tenant, recipient))
+	suppressed, err = isSuppressed(ctx, tenant, recipient)
+	assert.NoError(t, err)
+	assert.False(t, suppressed)
+
+	assert.ErrorIs(t, service.Remove(tenant, recipient), ErrSuppressionNotFound)
+
+	// Clean up
+	assert.NoError(t, redis.ClearSuppression(ctx, tenant, recipient))
+	database.DB.Where("recipient = ?", recipient).Delete(&models.Suppression{})
+}
+
+func TestSuppressionsAreScopedToTenant(t *testing.T) {
+	setupTest(t)
+	service := NewSuppressionService()
+	ctx := context.Background()
+	owner := models.DefaultTenantID
+	other := uint(9999)
+	recipient := "+905557654322"
+
+	_, err := service.Suppress(owner, recipient, models.SuppressionReasonOptOut, "", nil)
+	assert.NoError(t, err)
+
+	// Another tenant can neither see nor remove the entry
+	assert.ErrorIs(t, service.Remove(other, recipient), ErrSuppressionNotFound)
+	_, err = service.Get(other, recipient)
+	assert.ErrorIs(t, err, ErrSuppressionNotFound)
+	sups, err := service.List(other)
+	assert.NoError(t, err)
+	for _, sup := range sups {
+		assert.NotEqual(t, recipient, sup.Recipient)
+	}
+
+	// and its messages to the recipient are still sent
+	suppressed, err := isSuppressed(ctx, other, recipient)
+	assert.NoError(t, err)
+	assert.False(t, suppressed)
+	suppressed, err = isSuppressed(ctx, owner, recipient)
+	assert.NoError(t, err)
+	assert.True(t, suppressed)
+
+	// Clean up
+	assert.NoError(t, service.Remove(owner, recipient))
+	assert.NoError(t, redis.ClearSuppression(ctx, other, recipient))
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+)
+
+const maxTemplateNameLength = 100
+
+var (
+	// ErrInvalidTemplate is returned when a template fails validation
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrTemplateNotFound is returned when a template does not exist
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrMissingTemplateVariables is returned when a message does not supply
+	// every variable its template uses
+	ErrMissingTemplateVariables = errors.New("missing template variables")
+)
+
+var (
+	placeholderName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	localePattern   = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+)
+
+// TemplateService manages a tenant's message templates
+type TemplateService struct{}
+
+func NewTemplateService() *TemplateService {
+	return &TemplateService{}
+}
+
+// CreateTemplate saves the next version of a tenant's template. variants
+// maps locales such as "tr" or "pt-BR" to their bodies.
+func (s *TemplateService) CreateTemplate(tenantID uint, name, body string, variants map[string]string) (*models.Template, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTemplateNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidTemplate, maxTemplateNameLength)
+	}
+	if body == "" {
+		return nil, fmt.Errorf("%w: body cannot be empty", ErrInvalidTemplate)
+	}
+	if _, err := parseTemplate(body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	tmpl := &models.Template{
+		TenantID: tenantID,
+		Name:     name,
+		Body:     body,
+	}
+	for locale, variantBody := range variants {
+		locale = normalizeLocale(locale)
+		if !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("%w: invalid locale %q", ErrInvalidTemplate, locale)
+		}
+		if variantBody == "" {
+			return nil, fmt.Errorf("%w: body for locale %s cannot be empty", ErrInvalidTemplate, locale)
+		}
+		if _, err := parseTemplate(variantBody); err != nil {
+			return nil, fmt.Errorf("%w: locale %s: %v", ErrInvalidTemplate, locale, err)
+		}
+		for _, existing := range tmpl.Variants {
+			if strings.EqualFold(existing.Locale, locale) {
+				return nil, fmt.Errorf("%w: locale %s is given twice", ErrInvalidTemplate, locale)
+			}
+		}
+		tmpl.Variants = append(tmpl.Variants, models.TemplateVariant{Locale: locale, Body: variantBody})
+	}
+	sort.Slice(tmpl.Variants, func(i, j int) bool {
+		return tmpl.Variants[i].Locale < tmpl.Variants[j].Locale
+	})
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.Template{}).
+			Where("tenant_id = ? AND name = ?", tenantID, name).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		tmpl.Version = latest + 1
+		return tx.Create(tmpl).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// ListTemplates returns every version of a tenant's templates
+func (s *TemplateService) ListTemplates(tenantID uint) ([]models.Template, error) {
+	var templates []models.Template
+	if err := database.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("locale")
+	}).Where("tenant_id = ?", tenantID).Order("name, version").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("error fetching templates: %v", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns one version of a tenant's template
+func (s *TemplateService) GetTemplate(tenantID, id uint) (*models.Template, error) {
+	return getTemplate(database.DB, tenantID, id)
+}
+
+func getTemplate(db *gorm.DB, tenantID, id uint) (*models.Template, error) {
+	var tmpl models.Template
+	err := db.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("locale")
+	}).Where("tenant_id = ?", tenantID).First(&tmpl, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching template: %v", err)
+	}
+	return &tmpl, nil
+}
+
+// templateBody picks a template's body for a locale: the variant for the
+// exact locale, then one for its language, then the default body
+func templateBody(tmpl *models.Template, locale string) string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return tmpl.Body
+	}
+	for _, variant := range tmpl.Variants {
+		if strings.EqualFold(variant.Locale, locale) {
+			return variant.Body
+		}
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	for _, variant := range tmpl.Variants {
+		if strings.EqualFold(variant.Locale, language) {
+			return variant.Body
+		}
+	}
+	return tmpl.Body
+}
+
+// normalizeLocale accepts both pt-BR and pt_BR
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+}
+
+// templatePart is literal text, or the name of a variable when variable is set
+type templatePart struct {
+	text     string
+	variable bool
+}
+
+// parseTemplate splits a body into text and {{variable}} placeholders.
+// Whitespace inside the braces is ignored.
+func parseTemplate(body string) ([]templatePart, error) {
+	var parts []templatePart
+	for body != "" {
+		start := strings.Index(body, "{{")
+		if start < 0 {
+			parts = append(parts, templatePart{text: body})
+			break
+		}
+		end := strings.Index(body[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder %q", body[start:])
+		}
+		placeholder := body[start : start+end+2]
+		name := strings.TrimSpace(placeholder[2 : len(placeholder)-2])
+		if !placeholderName.MatchString(name) {
+			return nil, fmt.Errorf("invalid placeholder %q", placeholder)
+		}
+
+		if start > 0 {
+			parts = append(parts, templatePart{text: body[:start]})
+		}
+		parts = append(parts, templatePart{text: name, variable: true})
+		body = body[start+end+2:]
+	}
+	return parts, nil
+}
+
+// renderTemplate substitutes variables into a body. Every placeholder must
+// have a variable; unused variables are ignored.
+func renderTemplate(body string, variables map[string]string) (string, error) {
+	parts, err := parseTemplate(body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	var b strings.Builder
+	var missing []string
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		if !part.variable {
+			b.WriteString(part.text)
+			continue
+		}
+		value, ok := variables[part.text]
+		if !ok {
+			if !seen[part.text] {
+				seen[part.text] = true
+				missing = append(missing, part.text)
+			}
+			continue
+		}
+		b.WriteString(value)
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingTemplateVariables, strings.Join(missing, ", "))
+	}
+	return b.String(), nil
+}
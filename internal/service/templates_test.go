@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		variables map[string]string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "Substitutes variables",
+			body:      "Hi {{name}}, your code is {{ code }}",
+			variables: map[string]string{"name": "Ada", "code": "1234"},
+			want:      "Hi Ada, your code is 1234",
+		},
+		{
+			name:      "Repeated variable",
+			body:      "{{code}} is {{code}}",
+			variables: map[string]string{"code": "42"},
+			want:      "42 is 42",
+		},
+		{
+			name:      "Unused variables are ignored",
+			body:      "Hello",
+			variables: map[string]string{"name": "Ada"},
+			want:      "Hello",
+		},
+		{
+			name:      "Empty value",
+			body:      "Hi {{name}}!",
+			variables: map[string]string{"name": ""},
+			want:      "Hi !",
+		},
+		{
+			name:      "Missing variables",
+			body:      "Hi {{name}}, your code is {{code}} ({{name}})",
+			variables: map[string]string{},
+			wantErr:   ErrMissingTemplateVariables,
+		},
+		{
+			name:    "Unclosed placeholder",
+			body:    "Hi {{name",
+			wantErr: ErrInvalidTemplate,
+		},
+		{
+			name:    "Invalid placeholder",
+			body:    "Hi {{first name}}",
+			wantErr: ErrInvalidTemplate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate(tt.body, tt.variables)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderTemplateListsMissingVariables(t *testing.T) {
+	_, err := renderTemplate("Hi {{name}}, your code is {{code}} ({{name}})", nil)
+	assert.EqualError(t, err, "missing template variables: name, code")
+}
+
+func TestTemplateBody(t *testing.T) {
+	tmpl := &models.Template{
+		Body: "Hello",
+		Variants: []models.TemplateVariant{
+			{Locale: "pt", Body: "Olá"},
+			{Locale: "pt-BR", Body: "Oi"},
+			{Locale: "tr", Body: "Merhaba"},
+		},
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "", want: "Hello"},
+		{locale: "pt-BR", want: "Oi"},
+		{locale: "pt_br", want: "Oi"},
+		{locale: "pt-PT", want: "Olá"},
+		{locale: "tr-TR", want: "Merhaba"},
+		{locale: "de", want: "Hello"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, templateBody(tmpl, tt.locale), "locale=%q", tt.locale)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// ErrInvalidTenant is returned when a tenant fails validation
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// ErrTenantNotFound is returned when a tenant does not exist
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// TenantService manages the tenants sharing this deployment
+type TenantService struct{}
+
+func NewTenantService() *TenantService {
+	return &TenantService{}
+}
+
+// CreateTenant registers a tenant. providerName and rateLimit are optional
+// and fall back to the default provider and recipient limit; a dailyQuota
+// of 0 means unlimited.
+func (s *TenantService) CreateTenant(name, providerName, rateLimit string, dailyQuota int) (*models.Tenant, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidTenant)
+	}
+	if dailyQuota < 0 {
+		return nil, fmt.Errorf("%w: daily_quota cannot be negative", ErrInvalidTenant)
+	}
+
+	tenant := &models.Tenant{
+		Name:       name,
+		Provider:   providerName,
+		RateLimit:  rateLimit,
+		DailyQuota: dailyQuota,
+		Active:     true,
+	}
+	if _, err := tenantRoute(tenant); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTenant, err)
+	}
+
+	var existing int64
+	if err := database.DB.Model(&models.Tenant{}).Where("name = ?", name).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("error checking tenant name: %v", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("%w: name %q is taken", ErrInvalidTenant, name)
+	}
+
+	if err := database.DB.Create(tenant).Error; err != nil {
+		return nil, fmt.Errorf("error creating tenant: %v", err)
+	}
+	return tenant, nil
+}
+
+func (s *TenantService) ListTenants() ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	if err := database.DB.Order("id").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tenants: %v", err)
+	}
+	return tenants, nil
+}
+
+func (s *TenantService) GetTenant(id uint) (*models.Tenant, error) {
+	var tenant models.Tenant
+	err := database.DB.First(&tenant, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tenant: %v", err)
+	}
+	return &tenant, nil
+}
+
+// sendRoute is how a tenant's messages are sent: the gateway they go through
+// and the per-recipient limit they are held to
+type sendRoute struct {
+	gateway *provider.Config
+	// pinned is set when the tenant chose the gateway, which is then tried
+	// before the cheapest one
+	pinned bool
+	// limit replaces the configured recipient limit when set
+	limit *redis.RateLimit
+}
+
+// defaultRoute sends through the default provider under the configured limit
+func defaultRoute() *sendRoute {
+	return &sendRoute{gateway: provider.Default()}
+}
+
+// forChannel returns the route for messages of a channel: the tenant's
+// gateway if it serves the channel, the first gateway that does otherwise
+func (r *sendRoute) forChannel(channel string) (*sendRoute, error) {
+	if channel == "" {
+		channel = models.ChannelSMS
+	}
+	if r.gateway.ServesChannel(channel) {
+		return r, nil
+	}
+	gateway, ok := provider.ForChannel(channel)
+	if !ok {
+		return nil, fmt.Errorf("no provider is configured for %s messages", channel)
+	}
+	return r.via(gateway), nil
+}
+
+// via returns the route sending through another gateway under the same limit
+func (r *sendRoute) via(gateway *provider.Config) *sendRoute {
+	return &sendRoute{gateway: gateway, pinned: r.pinned, limit: r.limit}
+}
+
+// tenantRouteByID loads a tenant and resolves its route
+func tenantRouteByID(tx *gorm.DB, tenantID uint) (*sendRoute, error) {
+	var tenant models.Tenant
+	if err := tx.First(&tenant, tenantID).Error; err != nil {
+		return nil, fmt.Errorf("error fetching tenant %d: %v", tenantID, err)
+	}
+	return tenantRoute(&tenant)
+}
+
+// tenantRoute resolves a tenant's provider and rate limit settings
+func tenantRoute(tenant *models.Tenant) (*sendRoute, error) {
+	route := defaultRoute()
+	if tenant.Provider != "" {
+		gateway, ok := provider.Get(tenant.Provider)
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q", tenant.Provider)
+		}
+		route.gateway = gateway
+		route.pinned = true
+	}
+	if tenant.RateLimit != "" {
+		limit, err := redis.ParseRateLimit(tenant.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit: %v", err)
+		}
+		route.limit = &limit
+	}
+	return route, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/internal/provider"
+)
+
+func TestTenantRoute(t *testing.T) {
+	tests := []struct {
+		name      string
+		tenant    models.Tenant
+		wantLimit bool
+		wantErr   bool
+	}{
+		{name: "defaults", tenant: models.Tenant{}},
+		{name: "default provider by name", tenant: models.Tenant{Provider: provider.Default().Name}},
+		{name: "rate limit", tenant: models.Tenant{RateLimit: "token_bucket:5/1s"}, wantLimit: true},
+		{name: "unknown provider", tenant: models.Tenant{Provider: "nope"}, wantErr: true},
+		{name: "invalid rate limit", tenant: models.Tenant{RateLimit: "5 per second"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, err := tenantRoute(&tt.tenant)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, provider.Default().Name, route.gateway.Name)
+			assert.Equal(t, tt.wantLimit, route.limit != nil)
+		})
+	}
+}
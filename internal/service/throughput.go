@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+// globalThroughputKey is the single key shared by every replica and provider
+const globalThroughputKey = "all"
+
+// waitForThroughput blocks until both the provider and the global limiter
+// admit one more send, so bursts are spread out instead of tripping the
+// gateway's throttling. If Redis is unavailable sends go ahead unpaced.
+func waitForThroughput(ctx context.Context, gateway *provider.Config) error {
+	limit, err := gateway.Throughput()
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid throughput limit", "provider", gateway.Name, "error", err)
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		wait, err := reserveThroughput(ctx, gateway.Name, limit)
+		if err != nil {
+			slog.WarnContext(ctx, "Throughput check failed", "provider", gateway.Name, "error", err)
+			return nil
+		}
+		if wait == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserveThroughput takes a slot from the provider limiter and then the
+// global one, returning how long to wait if either is exhausted. A provider
+// slot taken when the global limiter then refuses is simply lost, which
+// errs on the side of sending too slowly rather than too fast.
+func reserveThroughput(ctx context.Context, providerName string, limit redis.RateLimit) (time.Duration, error) {
+	result, err := redis.AllowWithLimit(ctx, limit, redis.ClassProvider, providerName)
+	if err != nil {
+		return 0, err
+	}
+	if !result.Allowed {
+		return result.RetryAfter, nil
+	}
+
+	result, err = redis.Allow(ctx, redis.ClassGlobal, globalThroughputKey)
+	if err != nil {
+		return 0, err
+	}
+	if !result.Allowed {
+		return result.RetryAfter, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/provider"
+	"github.com/vkukul/messaging-system/pkg/redis"
+)
+
+func TestWaitForThroughput(t *testing.T) {
+	if err := redis.InitRedis(); err != nil {
+		t.Fatalf("Failed to initialize Redis: %v", err)
+	}
+
+	ctx := context.Background()
+	gateway := &provider.Config{Name: "throughput-test", RateLimit: "token_bucket:2/200ms"}
+	assert.NoError(t, redis.ResetRateLimit(ctx, redis.ClassProvider, gateway.Name))
+	assert.NoError(t, redis.ResetRateLimit(ctx, redis.ClassGlobal, globalThroughputKey))
+
+	// The first two sends use the burst, the next two wait for refills
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, waitForThroughput(ctx, gateway))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// A cancelled context stops waiting
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, waitForThroughput(cancelled, gateway))
+
+	assert.NoError(t, redis.ResetRateLimit(ctx, redis.ClassProvider, gateway.Name))
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/database"
+	"github.com/vkukul/messaging-system/pkg/signing"
+)
+
+const (
+	webhookBatchSize        = 50
+	webhookPollInterval     = 5 * time.Second
+	webhookMaxAttempts      = 8
+	webhookBaseBackoff      = 10 * time.Second
+	webhookMaxBackoff       = time.Hour
+	webhookSignatureVersion = "sha256="
+	// webhookClaimTimeout is how long a dispatcher holds the deliveries it
+	// claimed before another replica may take them over
+	webhookClaimTimeout = time.Minute
+	// minCallbackSecretLength is the shortest CALLBACK_SIGNING_SECRET accepted
+	minCallbackSecretLength = 32
+)
+
+// webhookEvents are the status changes clients can subscribe to
+var webhookEvents = map[string]bool{
+	models.EventMessageSent:          true,
+	models.EventMessageFailed:        true,
+	models.EventMessageDeadLettered:  true,
+	models.EventMessageDelivered:     true,
+	models.EventMessageUndeliverable: true,
+	models.EventMessageExpired:       true,
+	models.EventMessageInbound:       true,
+	models.EventMessageSuppressed:    true,
+	models.EventMessageNeedsReview:   true,
+	models.EventMessageFallback:      true,
+}
+
+// ErrInvalidWebhook is returned when a subscription fails validation
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrWebhookNotFound is returned when a subscription does not exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// errBlockedDestination is returned for webhooks aimed at internal addresses
+var errBlockedDestination = errors.New("callback URL must not target a loopback, link-local or private address")
+
+// WebhookService manages client webhook subscriptions
+type WebhookService struct{}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{}
+}
+
+// CreateSubscription registers a callback URL for the given events of a
+// tenant's messages. An empty event list subscribes to every status change.
+// A secret is generated when none is supplied.
+func (s *WebhookService) CreateSubscription(tenantID uint, callbackURL string, events []string, secret string) (*models.WebhookSubscription, error) {
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	sub := &models.WebhookSubscription{
+		TenantID: tenantID,
+		URL:      callbackURL,
+		Secret:   secret,
+		Events:   strings.Join(events, ","),
+		Active:   true,
+	}
+	if err := database.DB.Create(sub).Error; err != nil {
+		return nil, fmt.Errorf("error creating webhook subscription: %v", err)
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) ListSubscriptions(tenantID uint) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	if err := database.DB.Where("tenant_id = ?", tenantID).Order("id").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("error fetching webhook subscriptions: %v", err)
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) DeleteSubscription(tenantID, id uint) error {
+	result := database.DB.Where("tenant_id = ?", tenantID).Delete(&models.WebhookSubscription{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("error deleting webhook subscription: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns the delivery log of a tenant's subscription, newest first
+func (s *WebhookService) ListDeliveries(tenantID, subscriptionID uint) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := database.DB.Where("subscription_id = ?", subscriptionID).
+		Where("subscription_id IN (?)", database.DB.Model(&models.WebhookSubscription{}).Select("id").Where("tenant_id = ?", tenantID)).
+		Order("id desc").
+		Limit(100).
+		Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("error fetching webhook deliveries: %v", err)
+	}
+	return deliveries, nil
+}
+
+// validateCallbackURL checks that a client callback URL is an absolute https
+// URL that does not name a loopback, link-local or private host. Host names
+// are checked again once resolved, each time a webhook is sent.
+func validateCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("callback URL must be an absolute https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", errBlockedDestination, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errBlockedDestination, host)
+	}
+	return nil
+}
+
+// blockedNetworks are reserved ranges the net.IP checks do not cover
+var blockedNetworks = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15")
+
+// isPublicIP reports whether webhooks may be sent to ip
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// newWebhookClient returns the client webhooks are sent with. It only
+// connects to public addresses, checked after the host name is resolved so
+// DNS cannot point a registered URL at an internal service, and does not
+// follow redirects.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", errBlockedDestination, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: otelhttp.NewTransport(&http.Transport{
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		}),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating secret: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func subscribedTo(sub *models.WebhookSubscription, eventType string) bool {
+	if sub.Events == "" {
+		return true
+	}
+	for _, event := range strings.Split(sub.Events, ",") {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueStatusWebhooks queues a delivery to every matching subscription of
+// the message's tenant and to the message's own callback URL using the
+// caller's transaction
+func enqueueStatusWebhooks(tx *gorm.DB, msg *models.Message, eventType string) error {
+	return enqueueWebhooks(tx, msg.TenantID, msg.ID, msg.CallbackURL, eventType, messageEventPayload{
+		OccurredAt: time.Now(),
+		Message:    msg,
+	})
+}
+
+func enqueueWebhooks(tx *gorm.DB, tenantID, messageID uint, callbackURL, eventType string, payload messageEventPayload) error {
+	if !webhookEvents[eventType] {
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling webhook payload: %v", err)
+	}
+
+	var subs []models.WebhookSubscription
+	if err := tx.Where("tenant_id = ? AND active = ?", tenantID, true).Find(&subs).Error; err != nil {
+		return fmt.Errorf("error fetching webhook subscriptions: %v", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	for i := range subs {
+		if !subscribedTo(&subs[i], eventType) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: &subs[i].ID,
+			MessageID:      messageID,
+			URL:            subs[i].URL,
+			EventType:      eventType,
+			Payload:        string(data),
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  time.Now(),
+		})
+	}
+	if callbackURL != "" {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			MessageID:     messageID,
+			URL:           callbackURL,
+			EventType:     eventType,
+			Payload:       string(data),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("error queueing webhook deliveries: %v", err)
+	}
+	return nil
+}
+
+// webhookBackoff returns the wait before the next attempt after the given
+// number of failed attempts
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// WebhookDispatcher delivers queued status-change webhooks with retries
+type WebhookDispatcher struct {
+	client *http.Client
+	// defaultSecret signs deliveries to per-message callback URLs
+	defaultSecret string
+	interval      time.Duration
+}
+
+// NewWebhookDispatcher returns a dispatcher signing per-message callbacks
+// with CALLBACK_SIGNING_SECRET, which is required so that no webhook goes
+// out unsigned
+func NewWebhookDispatcher() (*WebhookDispatcher, error) {
+	secret := getEnv("CALLBACK_SIGNING_SECRET", "")
+	if len(secret) < minCallbackSecretLength {
+		return nil, fmt.Errorf("CALLBACK_SIGNING_SECRET must be at least %d characters", minCallbackSecretLength)
+	}
+	return &WebhookDispatcher{
+		client:        newWebhookClient(),
+		defaultSecret: secret,
+		interval:      webhookPollInterval,
+	}, nil
+}
+
+// Run delivers due webhooks until the context is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.deliverDue(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error delivering webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue delivers one batch of due webhooks. The batch is claimed in a
+// short transaction and delivered after it commits, so no row locks are
+// held across client requests.
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) error {
+	deliveries, err := claimWebhookDeliveries(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		secret, found, err := d.secretFor(ctx, delivery)
+		if err != nil {
+			return err
+		}
+		if !found {
+			// The subscription was deleted after this delivery was queued
+			delivery.Status = models.DeliveryFailed
+			delivery.LastError = "subscription deleted"
+			if err := database.DB.WithContext(ctx).Save(delivery).Error; err != nil {
+				return fmt.Errorf("error updating webhook delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		code, sendErr := d.deliver(ctx, delivery, secret)
+		delivery.Attempts++
+		delivery.ResponseCode = code
+		if sendErr == nil {
+			now := time.Now()
+			delivery.Status = models.DeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.LastError = ""
+		} else {
+			delivery.LastError = sendErr.Error()
+			if delivery.Attempts >= webhookMaxAttempts {
+				delivery.Status = models.DeliveryFailed
+			} else {
+				delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+			}
+			slog.WarnContext(ctx, "Webhook delivery failed",
+				"delivery_id", delivery.ID, "message_id", delivery.MessageID, "url", delivery.URL,
+				"attempt", delivery.Attempts, "error", sendErr)
+		}
+
+		if err := database.DB.WithContext(ctx).Save(delivery).Error; err != nil {
+			return fmt.Errorf("error updating webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// claimWebhookDeliveries claims the due deliveries by moving their next
+// attempt past the claim timeout, so other replicas skip them while they are
+// delivered and take them over if this one dies. Rows are locked with SKIP
+// LOCKED while claiming.
+func claimWebhookDeliveries(ctx context.Context) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(webhookBatchSize).
+			Find(&deliveries).Error; err != nil {
+			return fmt.Errorf("error fetching webhook deliveries: %v", err)
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		if err := tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(webhookClaimTimeout)).Error; err != nil {
+			return fmt.Errorf("error claiming webhook deliveries: %v", err)
+		}
+		return nil
+	})
+	return deliveries, err
+}
+
+func (d *WebhookDispatcher) secretFor(ctx context.Context, delivery *models.WebhookDelivery) (string, bool, error) {
+	if delivery.SubscriptionID == nil {
+		return d.defaultSecret, true, nil
+	}
+
+	var sub models.WebhookSubscription
+	if err := database.DB.WithContext(ctx).First(&sub, *delivery.SubscriptionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("error fetching webhook subscription: %v", err)
+	}
+	return sub.Secret, true, nil
+}
+
+// deliver POSTs a single webhook and returns the response status code
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery, secret string) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", webhookSignatureVersion+signing.Sign(secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	observeWebhook(metrics.DestinationClient, start, resp, err)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
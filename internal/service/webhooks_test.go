@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+	"github.com/vkukul/messaging-system/pkg/signing"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 4, want: 80 * time.Second},
+		{attempts: 20, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookBackoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+func TestSubscribedTo(t *testing.T) {
+	all := &models.WebhookSubscription{}
+	failures := &models.WebhookSubscription{Events: "message.failed,message.dead_lettered"}
+
+	assert.True(t, subscribedTo(all, models.EventMessageSent))
+	assert.True(t, subscribedTo(failures, models.EventMessageDeadLettered))
+	assert.False(t, subscribedTo(failures, models.EventMessageSent))
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://example.com/hooks", wantErr: false},
+		{url: "https://8.8.8.8/hooks", wantErr: false},
+		{url: "http://example.com/hooks", wantErr: true},
+		{url: "https://localhost:9000/hooks", wantErr: true},
+		{url: "https://api.localhost/hooks", wantErr: true},
+		{url: "https://127.0.0.1/hooks", wantErr: true},
+		{url: "https://[::1]/hooks", wantErr: true},
+		{url: "https://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "https://10.0.0.1/hooks", wantErr: true},
+		{url: "https://192.168.1.1/hooks", wantErr: true},
+		{url: "https://0.0.0.0/hooks", wantErr: true},
+		{url: "ftp://example.com/hooks", wantErr: true},
+		{url: "/hooks", wantErr: true},
+		{url: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := validateCallbackURL(tt.url)
+			assert.Equal(t, tt.wantErr, err != nil, "err = %v", err)
+		})
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// The address is checked again when the connection is dialed
+	resp, err := newWebhookClient().Post(server.URL, "application/json", strings.NewReader("{}"))
+	if resp != nil {
+		resp.Body.Close()
+	}
+	assert.ErrorIs(t, err, errBlockedDestination)
+	assert.False(t, called)
+}
+
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		statusCode int
+		wantErr    bool
+	}{
+		{
+			name:       "Signed delivery",
+			secret:     "secret",
+			statusCode: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:       "Receiver error",
+			secret:     "secret",
+			statusCode: http.StatusServiceUnavailable,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHeaders http.Header
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeaders = r.Header
+				gotBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			dispatcher := &WebhookDispatcher{client: server.Client()}
+			delivery := &models.WebhookDelivery{
+				ID:        3,
+				URL:       server.URL,
+				EventType: models.EventMessageSent,
+				Payload:   `{"message":{"id":1,"status":"sent"}}`,
+			}
+
+			code, err := dispatcher.deliver(context.Background(), delivery, tt.secret)
+			assert.Equal(t, tt.statusCode, code)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, delivery.Payload, string(gotBody))
+			assert.Equal(t, "3", gotHeaders.Get("X-Webhook-ID"))
+			assert.Equal(t, models.EventMessageSent, gotHeaders.Get("X-Webhook-Event"))
+
+			timestamp, err := strconv.ParseInt(gotHeaders.Get("X-Webhook-Timestamp"), 10, 64)
+			assert.NoError(t, err)
+			assert.Equal(t, "sha256="+signing.Sign(tt.secret, timestamp, gotBody), gotHeaders.Get("X-Webhook-Signature"))
+		})
+	}
+}
+
+func TestNewWebhookDispatcher(t *testing.T) {
+	t.Setenv("CALLBACK_SIGNING_SECRET", "")
+	_, err := NewWebhookDispatcher()
+	assert.Error(t, err)
+
+	t.Setenv("CALLBACK_SIGNING_SECRET", "too-short")
+	_, err = NewWebhookDispatcher()
+	assert.Error(t, err)
+
+	t.Setenv("CALLBACK_SIGNING_SECRET", strings.Repeat("s", minCallbackSecretLength))
+	dispatcher, err := NewWebhookDispatcher()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("s", minCallbackSecretLength), dispatcher.defaultSecret)
+}
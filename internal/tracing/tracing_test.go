@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+	}{
+		{name: "No exporter", exporter: ExporterNone, wantErr: false},
+		{name: "Stdout exporter", exporter: ExporterStdout, wantErr: false},
+		{name: "Unknown exporter", exporter: "zipkin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_EXPORTER", tt.exporter)
+			shutdown, err := Init(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			// Spans get real trace IDs even when nothing is exported
+			_, span := Tracer().Start(context.Background(), "test")
+			assert.True(t, span.SpanContext().IsValid())
+			span.End()
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "create")
+	defer span.End()
+
+	traceparent := Inject(ctx)
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, traceparent)
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), traceparent))
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+	assert.True(t, remote.IsRemote())
+
+	// Without a span there is nothing to propagate
+	assert.Empty(t, Inject(context.Background()))
+	assert.False(t, trace.SpanContextFromContext(Extract(context.Background(), "")).IsValid())
+}
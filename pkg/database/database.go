@@ -2,7 +2,7 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/vkukul/messaging-system/internal/models"
@@ -27,17 +27,52 @@ func InitDB() error {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
+	if err := registerMetrics(db); err != nil {
+		return fmt.Errorf("failed to register database metrics: %v", err)
+	}
+	if err := registerTracing(db); err != nil {
+		return fmt.Errorf("failed to register database tracing: %v", err)
+	}
+
 	DB = db
-	log.Println("Database connection established")
+	slog.Info("Database connection established")
 
 	// Auto migrate the schema
-	if err := DB.AutoMigrate(&models.Message{}); err != nil {
+	if err := DB.AutoMigrate(
+		&models.Message{},
+		&models.OutboxEvent{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.DeliveryReceipt{},
+		&models.InboundMessage{},
+		&models.Suppression{},
+		&models.Tenant{},
+		&models.APIKey{},
+		&models.IdempotencyRecord{},
+		&models.Template{},
+		&models.TemplateVariant{},
+		&models.DeliveryAttempt{},
+	); err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	if err := ensureDefaultTenant(); err != nil {
+		return fmt.Errorf("failed to create default tenant: %v", err)
+	}
+
 	return nil
 }
 
+// ensureDefaultTenant creates the tenant that owns pre-existing rows. The id
+// is fixed, so the sequence is moved past it afterwards.
+func ensureDefaultTenant() error {
+	if err := DB.Exec(`INSERT INTO tenants (id, name, active, daily_quota, created_at, updated_at)
+		VALUES (?, 'default', TRUE, 0, NOW(), NOW()) ON CONFLICT (id) DO NOTHING`, models.DefaultTenantID).Error; err != nil {
+		return err
+	}
+	return DB.Exec(`SELECT setval(pg_get_serial_sequence('tenants', 'id'), GREATEST((SELECT MAX(id) FROM tenants), 1))`).Error
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+)
+
+const metricsCallback = "metrics:errors"
+
+// registerMetrics counts failed operations of every kind GORM runs. A
+// lookup that finds no record is an expected outcome, not an error.
+func registerMetrics(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register(metricsCallback, recordError("create")); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:query").Register(metricsCallback, recordError("query")); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register(metricsCallback, recordError("update")); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register(metricsCallback, recordError("delete")); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("gorm:row").Register(metricsCallback, recordError("row")); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register(metricsCallback, recordError("raw"))
+}
+
+func recordError(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			metrics.DatabaseErrors.WithLabelValues(operation).Inc()
+		}
+	}
+}
@@ -0,0 +1,16 @@
+-- Create outbox events table
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id SERIAL PRIMARY KEY,
+    aggregate_type VARCHAR(255) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    dispatched BOOLEAN DEFAULT FALSE,
+    dispatched_at TIMESTAMP,
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate_id ON outbox_events (aggregate_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched ON outbox_events (dispatched);
@@ -0,0 +1,18 @@
+-- Track handset delivery reported by the gateway
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_status VARCHAR(32);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_messages_message_id ON messages (message_id);
+
+-- Create delivery receipts table
+CREATE TABLE IF NOT EXISTS delivery_receipts (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(255) NOT NULL,
+    provider_message_id VARCHAR(255) NOT NULL,
+    message_id INTEGER,
+    status VARCHAR(32),
+    raw_payload TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_receipts_provider_message_id ON delivery_receipts (provider_message_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_receipts_message_id ON delivery_receipts (message_id);
@@ -0,0 +1,17 @@
+-- Create inbound messages table
+CREATE TABLE IF NOT EXISTS inbound_messages (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(255) NOT NULL,
+    provider_message_id VARCHAR(255),
+    "from" VARCHAR(255) NOT NULL,
+    "to" VARCHAR(255),
+    content TEXT,
+    keyword VARCHAR(32),
+    related_message_id INTEGER,
+    received_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_provider_message_id ON inbound_messages (provider_message_id);
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_from ON inbound_messages ("from");
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_related_message_id ON inbound_messages (related_message_id);
@@ -0,0 +1,12 @@
+-- Create suppressions table
+CREATE TABLE IF NOT EXISTS suppressions (
+    id SERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    note TEXT,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suppressions_recipient ON suppressions (recipient);
@@ -0,0 +1,3 @@
+-- Defer rate-limited messages until their limiter resets
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_messages_next_attempt_at ON messages (next_attempt_at);
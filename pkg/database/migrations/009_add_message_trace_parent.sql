@@ -0,0 +1,2 @@
+-- Remember the trace a message was created in so sending joins it
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS trace_parent VARCHAR(55);
@@ -0,0 +1,2 @@
+-- Per-key cap on messages created per day; 0 uses the deployment default
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS daily_message_quota BIGINT DEFAULT 0;
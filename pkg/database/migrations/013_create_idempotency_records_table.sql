@@ -0,0 +1,16 @@
+-- Responses to requests sent with an Idempotency-Key, used while Redis is unavailable
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    completed BOOLEAN DEFAULT FALSE,
+    status_code INTEGER,
+    response_body TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_records_tenant_key ON idempotency_records (tenant_id, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_records_expires_at ON idempotency_records (expires_at);
@@ -0,0 +1,4 @@
+-- Claim messages before calling the gateway so an unrecorded send is not repeated
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS send_token VARCHAR(36);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sending_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_messages_send_token ON messages (send_token);
@@ -0,0 +1,24 @@
+-- Create templates tables
+CREATE TABLE IF NOT EXISTS templates (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL DEFAULT 1,
+    name VARCHAR(100) NOT NULL,
+    version INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_templates_tenant_name_version ON templates (tenant_id, name, version);
+
+CREATE TABLE IF NOT EXISTS template_variants (
+    id SERIAL PRIMARY KEY,
+    template_id INTEGER NOT NULL REFERENCES templates (id) ON DELETE CASCADE,
+    locale VARCHAR(35) NOT NULL,
+    body TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_template_variants_template_locale ON template_variants (template_id, locale);
+
+-- Remember which template a message was rendered from
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS template_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_messages_template_id ON messages (template_id);
@@ -0,0 +1,4 @@
+-- Allow concatenated SMS and record how many segments each message is billed as
+ALTER TABLE messages ALTER COLUMN content TYPE TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS encoding VARCHAR(8);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS segments INTEGER DEFAULT 1;
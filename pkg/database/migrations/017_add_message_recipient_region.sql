@@ -0,0 +1,4 @@
+-- Record the country of each recipient for routing and pricing
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS country_code INTEGER;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS region VARCHAR(2);
+CREATE INDEX IF NOT EXISTS idx_messages_region ON messages (region);
@@ -0,0 +1,8 @@
+-- Send email and push notifications alongside SMS
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS channel VARCHAR(8) NOT NULL DEFAULT 'sms';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS subject TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS html_body TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachments JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS data JSONB;
+CREATE INDEX IF NOT EXISTS idx_messages_channel ON messages (channel);
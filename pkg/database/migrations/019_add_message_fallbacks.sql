@@ -0,0 +1,8 @@
+-- Link the channel attempts of a logical message and find attempts awaiting fallback
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS original_id INTEGER;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS fallback_id INTEGER;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS fallbacks JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS fallback_timeout INTEGER DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_messages_original_id ON messages (original_id);
+CREATE INDEX IF NOT EXISTS idx_messages_fallback_pending ON messages (sent_at)
+    WHERE fallback_timeout > 0 AND fallback_id IS NULL;
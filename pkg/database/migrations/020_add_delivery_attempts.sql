@@ -0,0 +1,16 @@
+-- Record the provider each message went through and every gateway request made for it
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider VARCHAR(100);
+CREATE TABLE IF NOT EXISTS delivery_attempts (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1,
+    provider VARCHAR(100) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    error_class VARCHAR(32),
+    cost NUMERIC,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_delivery_attempts_message_id ON delivery_attempts (message_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_attempts_tenant_id ON delivery_attempts (tenant_id);
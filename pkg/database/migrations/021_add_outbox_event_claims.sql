@@ -0,0 +1,4 @@
+-- Publish outbox events outside the claiming transaction and give up on events that keep failing
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_failed_at ON outbox_events (failed_at);
@@ -0,0 +1,2 @@
+-- Send STOP confirmations to the recipient they just suppressed
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS opt_out_confirmation BOOLEAN DEFAULT FALSE;
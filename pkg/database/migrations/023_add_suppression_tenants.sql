@@ -0,0 +1,12 @@
+-- Keep a suppression list per tenant
+ALTER TABLE suppressions ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1;
+
+DROP INDEX IF EXISTS idx_suppressions_recipient;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suppressions_tenant_recipient ON suppressions (tenant_id, recipient);
+
+-- Entries of the shared list keep applying to every tenant
+INSERT INTO suppressions (tenant_id, recipient, reason, note, expires_at, created_at, updated_at)
+SELECT tenants.id, suppressions.recipient, suppressions.reason, suppressions.note, suppressions.expires_at, suppressions.created_at, suppressions.updated_at
+FROM suppressions CROSS JOIN tenants
+WHERE suppressions.tenant_id = 1 AND tenants.id <> 1
+ON CONFLICT (tenant_id, recipient) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Let the reconciler claim stuck messages without holding row locks while it asks gateways
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reconcile_after TIMESTAMP;
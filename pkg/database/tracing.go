@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/vkukul/messaging-system/internal/tracing"
+)
+
+const spanKey = "tracing:span"
+
+// registerTracing wraps every GORM operation in a span. Operations whose
+// context carries no span, such as background polling, are not traced.
+func registerTracing(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tracing:before_create", startSpan("create")); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("gorm:create").Register("tracing:after_create", endSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tracing:before_query", startSpan("query")); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:query").Register("tracing:after_query", endSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tracing:before_update", startSpan("update")); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("tracing:after_update", endSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("delete")); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tracing:before_row", startSpan("row")); err != nil {
+		return err
+	}
+	if err := callbacks.Row().After("gorm:row").Register("tracing:after_row", endSpan); err != nil {
+		return err
+	}
+	if err := callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("raw")); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan)
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := tracing.Tracer().Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", tx.Statement.Table),
+			))
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(
+		attribute.String("db.statement", tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,255 @@
+// Package phone normalizes phone numbers to E.164.
+//
+// Numbers in international format ("+90 532 123 45 67" or
+// "0090 532 123 45 67") are accepted for every assigned country calling
+// code. Numbers in national format ("0532 123 45 67") need a default region
+// whose trunk prefix is dropped. National number lengths are checked for
+// the regions listed in this package; other countries are only held to the
+// 15 digit limit of E.164.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidNumber is returned when a phone number cannot be normalized
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+const (
+	maxE164Digits     = 15
+	minNationalDigits = 4
+)
+
+// Number is a normalized phone number
+type Number struct {
+	// E164 is the number as "+<country code><national number>"
+	E164        string
+	CountryCode int
+	// National is the national significant number, without trunk prefix
+	National string
+	// Region is the ISO 3166-1 alpha-2 code of the country, or "" if the
+	// calling code is not in this package's region table. Calling codes
+	// shared by several countries map to the largest, e.g. +1 to US.
+	Region string
+}
+
+// region holds the numbering rules of one country
+type region struct {
+	code int
+	// trunk is the prefix dialled before national numbers inside the country
+	trunk     string
+	minLength int
+	maxLength int
+}
+
+// regions lists the countries whose numbers are fully checked
+var regions = map[string]region{
+	"US": {code: 1, trunk: "1", minLength: 10, maxLength: 10},
+	"CA": {code: 1, trunk: "1", minLength: 10, maxLength: 10},
+	"RU": {code: 7, trunk: "8", minLength: 10, maxLength: 10},
+	"KZ": {code: 7, trunk: "8", minLength: 10, maxLength: 10},
+	"EG": {code: 20, trunk: "0", minLength: 8, maxLength: 10},
+	"ZA": {code: 27, trunk: "0", minLength: 9, maxLength: 9},
+	"GR": {code: 30, minLength: 10, maxLength: 10},
+	"NL": {code: 31, trunk: "0", minLength: 9, maxLength: 9},
+	"BE": {code: 32, trunk: "0", minLength: 8, maxLength: 9},
+	"FR": {code: 33, trunk: "0", minLength: 9, maxLength: 9},
+	"ES": {code: 34, minLength: 9, maxLength: 9},
+	"HU": {code: 36, trunk: "06", minLength: 8, maxLength: 9},
+	"IT": {code: 39, minLength: 6, maxLength: 11},
+	"RO": {code: 40, trunk: "0", minLength: 9, maxLength: 9},
+	"CH": {code: 41, trunk: "0", minLength: 9, maxLength: 9},
+	"AT": {code: 43, trunk: "0", minLength: 4, maxLength: 13},
+	"GB": {code: 44, trunk: "0", minLength: 9, maxLength: 10},
+	"DK": {code: 45, minLength: 8, maxLength: 8},
+	"SE": {code: 46, trunk: "0", minLength: 7, maxLength: 10},
+	"NO": {code: 47, minLength: 8, maxLength: 8},
+	"PL": {code: 48, minLength: 9, maxLength: 9},
+	"DE": {code: 49, trunk: "0", minLength: 6, maxLength: 13},
+	"MX": {code: 52, minLength: 10, maxLength: 10},
+	"AR": {code: 54, trunk: "0", minLength: 10, maxLength: 11},
+	"BR": {code: 55, trunk: "0", minLength: 10, maxLength: 11},
+	"CO": {code: 57, minLength: 10, maxLength: 10},
+	"AU": {code: 61, trunk: "0", minLength: 9, maxLength: 9},
+	"ID": {code: 62, trunk: "0", minLength: 8, maxLength: 12},
+	"PH": {code: 63, trunk: "0", minLength: 10, maxLength: 10},
+	"NZ": {code: 64, trunk: "0", minLength: 8, maxLength: 10},
+	"SG": {code: 65, minLength: 8, maxLength: 8},
+	"TH": {code: 66, trunk: "0", minLength: 8, maxLength: 9},
+	"JP": {code: 81, trunk: "0", minLength: 9, maxLength: 10},
+	"KR": {code: 82, trunk: "0", minLength: 8, maxLength: 10},
+	"VN": {code: 84, trunk: "0", minLength: 9, maxLength: 10},
+	"CN": {code: 86, trunk: "0", minLength: 10, maxLength: 11},
+	"TR": {code: 90, trunk: "0", minLength: 10, maxLength: 10},
+	"IN": {code: 91, trunk: "0", minLength: 10, maxLength: 10},
+	"PK": {code: 92, trunk: "0", minLength: 9, maxLength: 10},
+	"IR": {code: 98, trunk: "0", minLength: 10, maxLength: 10},
+	"MA": {code: 212, trunk: "0", minLength: 9, maxLength: 9},
+	"NG": {code: 234, trunk: "0", minLength: 8, maxLength: 10},
+	"KE": {code: 254, trunk: "0", minLength: 9, maxLength: 9},
+	"PT": {code: 351, minLength: 9, maxLength: 9},
+	"IE": {code: 353, trunk: "0", minLength: 7, maxLength: 9},
+	"FI": {code: 358, trunk: "0", minLength: 5, maxLength: 12},
+	"BG": {code: 359, trunk: "0", minLength: 8, maxLength: 9},
+	"UA": {code: 380, trunk: "0", minLength: 9, maxLength: 9},
+	"RS": {code: 381, trunk: "0", minLength: 8, maxLength: 9},
+	"CZ": {code: 420, minLength: 9, maxLength: 9},
+	"SK": {code: 421, trunk: "0", minLength: 9, maxLength: 9},
+	"AE": {code: 971, trunk: "0", minLength: 8, maxLength: 9},
+	"IL": {code: 972, trunk: "0", minLength: 8, maxLength: 9},
+	"SA": {code: 966, trunk: "0", minLength: 9, maxLength: 9},
+	"AZ": {code: 994, trunk: "0", minLength: 9, maxLength: 9},
+	"GE": {code: 995, trunk: "0", minLength: 9, maxLength: 9},
+}
+
+// primaryRegions picks the region of calling codes shared by several
+// countries in the table
+var primaryRegions = map[int]string{
+	1: "US",
+	7: "RU",
+}
+
+// callingCodes are the assigned ITU-T E.164 country calling codes
+var callingCodes = map[int]bool{}
+
+func init() {
+	codes := []int{
+		1, 7, 20, 27, 30, 31, 32, 33, 34, 36, 39, 40, 41, 43, 44, 45, 46, 47, 48, 49,
+		51, 52, 53, 54, 55, 56, 57, 58, 60, 61, 62, 63, 64, 65, 66, 81, 82, 84, 86,
+		90, 91, 92, 93, 94, 95, 98,
+		211, 212, 213, 216, 218, 220, 221, 222, 223, 224, 225, 226, 227, 228, 229,
+		230, 231, 232, 233, 234, 235, 236, 237, 238, 239, 240, 241, 242, 243, 244,
+		245, 246, 247, 248, 249, 250, 251, 252, 253, 254, 255, 256, 257, 258, 260,
+		261, 262, 263, 264, 265, 266, 267, 268, 269, 290, 291, 297, 298, 299,
+		350, 351, 352, 353, 354, 355, 356, 357, 358, 359, 370, 371, 372, 373, 374,
+		375, 376, 377, 378, 380, 381, 382, 383, 385, 386, 387, 389, 420, 421, 423,
+		500, 501, 502, 503, 504, 505, 506, 507, 508, 509, 590, 591, 592, 593, 594,
+		595, 596, 597, 598, 599,
+		670, 672, 673, 674, 675, 676, 677, 678, 679, 680, 681, 682, 683, 685, 686,
+		687, 688, 689, 690, 691, 692,
+		800, 808, 850, 852, 853, 855, 856, 870, 878, 880, 881, 882, 883, 886, 888,
+		960, 961, 962, 963, 964, 965, 966, 967, 968, 970, 971, 972, 973, 974, 975,
+		976, 977, 979, 992, 993, 994, 995, 996, 998,
+	}
+	for _, code := range codes {
+		callingCodes[code] = true
+	}
+}
+
+// ValidRegion reports whether numbers in national format can be parsed for
+// a region
+func ValidRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// Parse normalizes a phone number. Spaces, dashes, dots and parentheses are
+// ignored. Numbers without a "+" or "00" prefix are read in the national
+// format of defaultRegion, which may be empty to accept only international
+// numbers.
+func Parse(input, defaultRegion string) (*Number, error) {
+	raw := strings.TrimSpace(input)
+	if raw == "" {
+		return nil, fmt.Errorf("%w: number is empty", ErrInvalidNumber)
+	}
+
+	var digits strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidNumber, r)
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case strings.HasPrefix(raw, "+"):
+		return parseInternational(number)
+	case strings.HasPrefix(number, "00"):
+		return parseInternational(number[2:])
+	}
+
+	if defaultRegion == "" {
+		return nil, fmt.Errorf("%w: number must be in international format, starting with + and the country code", ErrInvalidNumber)
+	}
+	regionCode := strings.ToUpper(defaultRegion)
+	rules, ok := regions[regionCode]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown default region %q", ErrInvalidNumber, defaultRegion)
+	}
+	national := number
+	if rules.trunk != "" && strings.HasPrefix(national, rules.trunk) && len(national)-len(rules.trunk) >= rules.minLength {
+		national = national[len(rules.trunk):]
+	}
+	return build(rules.code, national, regionCode)
+}
+
+// parseInternational reads digits following the international prefix
+func parseInternational(digits string) (*Number, error) {
+	for length := 1; length <= 3 && length < len(digits); length++ {
+		code, _ := strconv.Atoi(digits[:length])
+		if !callingCodes[code] {
+			continue
+		}
+		regionCode := primaryRegion(code)
+		national := digits[length:]
+		// Tolerate a trunk prefix written after the country code, as in
+		// "+44 (0)20 7946 0958"
+		if rules, ok := regions[regionCode]; ok && rules.trunk != "" &&
+			strings.HasPrefix(national, rules.trunk) && len(national) > rules.maxLength {
+			national = national[len(rules.trunk):]
+		}
+		return build(code, national, regionCode)
+	}
+	if digits == "" {
+		return nil, fmt.Errorf("%w: country code is missing", ErrInvalidNumber)
+	}
+	return nil, fmt.Errorf("%w: unknown country code in +%s", ErrInvalidNumber, digits)
+}
+
+// build validates the national number of a region and assembles the result
+func build(code int, national, regionCode string) (*Number, error) {
+	e164 := strconv.Itoa(code) + national
+	if len(e164) > maxE164Digits {
+		return nil, fmt.Errorf("%w: +%s is longer than %d digits", ErrInvalidNumber, e164, maxE164Digits)
+	}
+
+	minLength, maxLength := minNationalDigits, maxE164Digits-len(strconv.Itoa(code))
+	if rules, ok := regions[regionCode]; ok {
+		minLength, maxLength = rules.minLength, rules.maxLength
+	}
+	if len(national) < minLength {
+		return nil, fmt.Errorf("%w: +%s is too short for country code %d", ErrInvalidNumber, e164, code)
+	}
+	if len(national) > maxLength {
+		return nil, fmt.Errorf("%w: +%s is too long for country code %d", ErrInvalidNumber, e164, code)
+	}
+
+	return &Number{
+		E164:        "+" + e164,
+		CountryCode: code,
+		National:    national,
+		Region:      regionCode,
+	}, nil
+}
+
+// primaryRegion returns the region of a calling code, or "" if it is not
+// in the table
+func primaryRegion(code int) string {
+	if region, ok := primaryRegions[code]; ok {
+		return region
+	}
+	for name, rules := range regions {
+		if rules.code == code {
+			return name
+		}
+	}
+	return ""
+}
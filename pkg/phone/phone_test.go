@@ -0,0 +1,63 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		defaultRegion string
+		wantE164      string
+		wantCode      int
+		wantRegion    string
+		wantErr       bool
+	}{
+		{name: "E.164", input: "+905321234567", wantE164: "+905321234567", wantCode: 90, wantRegion: "TR"},
+		{name: "Spaces and dashes", input: "+90 532-123 45 67", wantE164: "+905321234567", wantCode: 90, wantRegion: "TR"},
+		{name: "00 prefix", input: "0090 532 123 45 67", wantE164: "+905321234567", wantCode: 90, wantRegion: "TR"},
+		{name: "National with trunk prefix", input: "0532 123 45 67", defaultRegion: "TR", wantE164: "+905321234567", wantCode: 90, wantRegion: "TR"},
+		{name: "National without trunk prefix", input: "(532) 123-4567", defaultRegion: "tr", wantE164: "+905321234567", wantCode: 90, wantRegion: "TR"},
+		{name: "Trunk prefix after country code", input: "+44 (0)20 7946 0958", wantE164: "+442079460958", wantCode: 44, wantRegion: "GB"},
+		{name: "NANP", input: "+1 (212) 555-0123", wantE164: "+12125550123", wantCode: 1, wantRegion: "US"},
+		{name: "NANP national", input: "1-212-555-0123", defaultRegion: "US", wantE164: "+12125550123", wantCode: 1, wantRegion: "US"},
+		{name: "Three digit country code", input: "+351 912 345 678", wantE164: "+351912345678", wantCode: 351, wantRegion: "PT"},
+		{name: "Trunk prefix not needed", input: "+359 88 123 4567", wantE164: "+359881234567", wantCode: 359, wantRegion: "BG"},
+		{name: "Country outside the region table", input: "+993 65 123456", wantE164: "+99365123456", wantCode: 993, wantRegion: ""},
+		{name: "Empty", input: "  ", wantErr: true},
+		{name: "Letters", input: "+90532CALLME", wantErr: true},
+		{name: "Plus in the middle", input: "90+5321234567", defaultRegion: "TR", wantErr: true},
+		{name: "National without default region", input: "05321234567", wantErr: true},
+		{name: "Unknown default region", input: "05321234567", defaultRegion: "XX", wantErr: true},
+		{name: "Unknown country code", input: "+8091234567", wantErr: true},
+		{name: "Too short for region", input: "+90532123456", wantErr: true},
+		{name: "Too long for region", input: "+9053212345678", wantErr: true},
+		{name: "Longer than E.164", input: "+9931234567890123", wantErr: true},
+		{name: "Country code only", input: "+90", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := Parse(tt.input, tt.defaultRegion)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidNumber), "got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantE164, number.E164)
+			assert.Equal(t, tt.wantCode, number.CountryCode)
+			assert.Equal(t, tt.wantRegion, number.Region)
+		})
+	}
+}
+
+func TestValidRegion(t *testing.T) {
+	assert.True(t, ValidRegion("TR"))
+	assert.True(t, ValidRegion("gb"))
+	assert.False(t, ValidRegion("XX"))
+	assert.False(t, ValidRegion(""))
+}
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+// IdempotencyKeyPrefix prefixes idempotency records, keyed as
+// idempotency:<tenant>:<key>
+const IdempotencyKeyPrefix = "idempotency:"
+
+func idempotencyKey(tenantID uint, key string) string {
+	return IdempotencyKeyPrefix + tenantKey(tenantID, key)
+}
+
+// ClaimIdempotencyKey stores record unless its key already has one, and
+// reports whether it was stored
+func ClaimIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, ttl time.Duration) (bool, error) {
+	data, err := marshalIdempotencyRecord(record)
+	if err != nil {
+		return false, err
+	}
+
+	var claimed bool
+	err = withRetry(func() error {
+		var err error
+		claimed, err = Client.SetNX(ctx, idempotencyKey(record.TenantID, record.Key), data, ttl).Result()
+		return err
+	})
+	return claimed, err
+}
+
+// SetIdempotencyRecord stores record, replacing the claim for its key
+func SetIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord, ttl time.Duration) error {
+	data, err := marshalIdempotencyRecord(record)
+	if err != nil {
+		return err
+	}
+
+	return withRetry(func() error {
+		return Client.Set(ctx, idempotencyKey(record.TenantID, record.Key), data, ttl).Err()
+	})
+}
+
+// GetIdempotencyRecord returns the record stored for a tenant's key, or nil
+// if there is none
+func GetIdempotencyRecord(ctx context.Context, tenantID uint, key string) (*models.IdempotencyRecord, error) {
+	if key == "" {
+		return nil, fmt.Errorf("idempotency key cannot be empty")
+	}
+
+	var data string
+	err := withRetry(func() error {
+		var err error
+		data, err = Client.Get(ctx, idempotencyKey(tenantID, key)).Result()
+		if err == redis.Nil {
+			data = ""
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, nil
+	}
+
+	var record models.IdempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency record: %v", err)
+	}
+	return &record, nil
+}
+
+// DeleteIdempotencyRecord removes the record stored for a tenant's key
+func DeleteIdempotencyRecord(ctx context.Context, tenantID uint, key string) error {
+	if key == "" {
+		return fmt.Errorf("idempotency key cannot be empty")
+	}
+
+	return withRetry(func() error {
+		return Client.Del(ctx, idempotencyKey(tenantID, key)).Err()
+	})
+}
+
+func marshalIdempotencyRecord(record *models.IdempotencyRecord) (string, error) {
+	if record == nil || record.Key == "" {
+		return "", fmt.Errorf("idempotency record must have a key")
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal idempotency record: %v", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkukul/messaging-system/internal/models"
+)
+
+func TestIdempotencyRecords(t *testing.T) {
+	setupLocalRedis(t)
+	ctx := context.Background()
+	claim := &models.IdempotencyRecord{TenantID: 1, Key: "test-idempotency", RequestHash: "abc"}
+	DeleteIdempotencyRecord(ctx, claim.TenantID, claim.Key)
+
+	record, err := GetIdempotencyRecord(ctx, claim.TenantID, claim.Key)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	claimed, err := ClaimIdempotencyKey(ctx, claim, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// The key can only be claimed once
+	claimed, err = ClaimIdempotencyKey(ctx, &models.IdempotencyRecord{TenantID: 1, Key: claim.Key, RequestHash: "def"}, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Other tenants have their own keys
+	claimed, err = ClaimIdempotencyKey(ctx, &models.IdempotencyRecord{TenantID: 2, Key: claim.Key, RequestHash: "abc"}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	completed := &models.IdempotencyRecord{TenantID: 1, Key: claim.Key, RequestHash: "abc", Completed: true, StatusCode: 201, ResponseBody: `{"id":1}`}
+	assert.NoError(t, SetIdempotencyRecord(ctx, completed, time.Hour))
+
+	record, err = GetIdempotencyRecord(ctx, claim.TenantID, claim.Key)
+	assert.NoError(t, err)
+	if assert.NotNil(t, record) {
+		assert.True(t, record.Completed)
+		assert.Equal(t, "abc", record.RequestHash)
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, `{"id":1}`, record.ResponseBody)
+	}
+
+	assert.NoError(t, DeleteIdempotencyRecord(ctx, 1, claim.Key))
+	assert.NoError(t, DeleteIdempotencyRecord(ctx, 2, claim.Key))
+	record, err = GetIdempotencyRecord(ctx, claim.TenantID, claim.Key)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+)
+
+// metricsHook counts failed commands. Cache misses and the NOSCRIPT reply
+// that makes scripts fall back from EVALSHA to EVAL are not failures.
+type metricsHook struct{}
+
+func (metricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	recordCommandError(cmd)
+	return nil
+}
+
+func (metricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		recordCommandError(cmd)
+	}
+	return nil
+}
+
+func recordCommandError(cmd redis.Cmder) {
+	if isCommandError(cmd.Err()) {
+		metrics.RedisErrors.WithLabelValues(cmd.Name()).Inc()
+	}
+}
+
+// isCommandError reports whether a command's error is a real failure
+func isCommandError(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil) && !strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
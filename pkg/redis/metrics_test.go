@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkukul/messaging-system/internal/metrics"
+)
+
+func TestRecordCommandError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		counted bool
+	}{
+		{name: "Success", err: nil, counted: false},
+		{name: "Cache miss", err: redis.Nil, counted: false},
+		{name: "Script not loaded", err: errors.New("NOSCRIPT No matching script"), counted: false},
+		{name: "Connection error", err: errors.New("dial tcp: connection refused"), counted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(metrics.RedisErrors.WithLabelValues("get"))
+
+			cmd := redis.NewStringCmd(context.Background(), "get", "key")
+			cmd.SetErr(tt.err)
+			recordCommandError(cmd)
+
+			after := testutil.ToFloat64(metrics.RedisErrors.WithLabelValues("get"))
+			if tt.counted {
+				assert.Equal(t, before+1, after)
+			} else {
+				assert.Equal(t, before, after)
+			}
+		})
+	}
+}
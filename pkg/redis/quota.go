@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// QuotaPrefix prefixes daily quota counters, keyed as
+// quota:<name>:<key>:<YYYY-MM-DD>
+const QuotaPrefix = "quota:"
+
+// Unlimited is the quota limit that never denies, while still counting usage
+const Unlimited int64 = -1
+
+// quotaGrace keeps a counter for a while after its day ends so usage
+// queried around midnight does not vanish early
+const quotaGrace = time.Hour
+
+// QuotaResult describes a daily quota after a check
+type QuotaResult struct {
+	Allowed bool
+	// Limit is Unlimited when the quota never denies
+	Limit int64
+	Used  int64
+	// Remaining is Unlimited when the quota never denies
+	Remaining int64
+	// ResetAt is the start of the next UTC day, when the counter starts over
+	ResetAt time.Time
+}
+
+// consumeQuotaScript counts cost units against a daily counter if they fit
+// the limit. A negative limit counts without denying.
+var consumeQuotaScript = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local cost = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local used = tonumber(redis.call('GET', key) or '0')
+if limit >= 0 and used + cost > limit then
+  return {0, used}
+end
+
+used = redis.call('INCRBY', key, cost)
+redis.call('PEXPIRE', key, ttl)
+return {1, used}
+`)
+
+// refundQuotaScript gives back units without taking the counter below zero
+var refundQuotaScript = redis.NewScript(`
+local key = KEYS[1]
+local cost = tonumber(ARGV[1])
+
+local used = tonumber(redis.call('GET', key) or '0')
+if used <= 0 then
+  return 0
+end
+return redis.call('DECRBY', key, math.min(cost, used))
+`)
+
+// ConsumeQuota counts one unit of the named daily quota for key, unless the
+// day's limit has been reached. Use Unlimited to only count usage.
+func ConsumeQuota(ctx context.Context, name, key string, limit int64, now time.Time) (*QuotaResult, error) {
+	if key == "" {
+		return nil, fmt.Errorf("quota key cannot be empty")
+	}
+
+	resetAt := nextUTCDay(now)
+	ttl := resetAt.Sub(now) + quotaGrace
+
+	var values []interface{}
+	err := withRetry(func() error {
+		var err error
+		values, err = consumeQuotaScript.Run(ctx, Client, []string{quotaKey(name, key, now)},
+			limit, 1, ttl.Milliseconds()).Slice()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("quota check failed: %v", err)
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("quota check failed: unexpected script result %v", values)
+	}
+
+	return newQuotaResult(values[0].(int64) == 1, limit, values[1].(int64), resetAt), nil
+}
+
+// RefundQuota gives back one unit consumed today, e.g. when the request it
+// was consumed for failed
+func RefundQuota(ctx context.Context, name, key string, now time.Time) error {
+	if key == "" {
+		return fmt.Errorf("quota key cannot be empty")
+	}
+
+	return withRetry(func() error {
+		return refundQuotaScript.Run(ctx, Client, []string{quotaKey(name, key, now)}, 1).Err()
+	})
+}
+
+// GetQuota returns today's usage of the named quota for key without
+// consuming any of it
+func GetQuota(ctx context.Context, name, key string, limit int64, now time.Time) (*QuotaResult, error) {
+	if key == "" {
+		return nil, fmt.Errorf("quota key cannot be empty")
+	}
+
+	var used int64
+	err := withRetry(func() error {
+		var err error
+		used, err = Client.Get(ctx, quotaKey(name, key, now)).Int64()
+		if err == redis.Nil {
+			used, err = 0, nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading quota: %v", err)
+	}
+
+	return newQuotaResult(limit < 0 || used < limit, limit, used, nextUTCDay(now)), nil
+}
+
+func newQuotaResult(allowed bool, limit, used int64, resetAt time.Time) *QuotaResult {
+	result := &QuotaResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Used:      used,
+		Remaining: Unlimited,
+		ResetAt:   resetAt,
+	}
+	if limit >= 0 {
+		result.Remaining = limit - used
+		if result.Remaining < 0 {
+			result.Remaining = 0
+		}
+	}
+	return result
+}
+
+func quotaKey(name, key string, now time.Time) string {
+	return QuotaPrefix + name + ":" + key + ":" + now.UTC().Format("2006-01-02")
+}
+
+// nextUTCDay returns the start of the UTC day after now
+func nextUTCDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
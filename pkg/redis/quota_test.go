@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumeQuota(t *testing.T) {
+	setupLocalRedis(t)
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+	key := "test-consume"
+
+	for i := int64(1); i <= 3; i++ {
+		result, err := ConsumeQuota(ctx, "messages", key, 3, now)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Used)
+		assert.Equal(t, 3-i, result.Remaining)
+	}
+
+	result, err := ConsumeQuota(ctx, "messages", key, 3, now)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, int64(3), result.Used)
+	assert.Equal(t, int64(0), result.Remaining)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), result.ResetAt)
+
+	// A refund makes room for one more
+	assert.NoError(t, RefundQuota(ctx, "messages", key, now))
+	result, err = ConsumeQuota(ctx, "messages", key, 3, now)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// The next day starts over
+	result, err = GetQuota(ctx, "messages", key, 3, now.Add(6*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Used)
+	assert.True(t, result.Allowed)
+}
+
+func TestConsumeQuotaUnlimited(t *testing.T) {
+	setupLocalRedis(t)
+	ctx := context.Background()
+	now := time.Now()
+	key := "test-unlimited"
+
+	for i := 0; i < 5; i++ {
+		result, err := ConsumeQuota(ctx, "messages", key, Unlimited, now)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, Unlimited, result.Remaining)
+	}
+
+	result, err := GetQuota(ctx, "messages", key, Unlimited, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.Used)
+
+	// Refunds never take the counter below zero
+	Client.Del(ctx, quotaKey("messages", key, now))
+	assert.NoError(t, RefundQuota(ctx, "messages", key, now))
+	result, err = GetQuota(ctx, "messages", key, Unlimited, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Used)
+}
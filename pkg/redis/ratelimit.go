@@ -0,0 +1,294 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmTokenBucket   = "token_bucket"
+
+	ClassRecipient = "recipient"
+	ClassProvider  = "provider"
+	ClassGlobal    = "global"
+	// ClassAPI limits requests per API client
+	ClassAPI = "api"
+)
+
+// RateLimit configures the limiter used for one class of keys
+type RateLimit struct {
+	Algorithm string
+	// Limit is the number of events allowed per Window for sliding windows,
+	// and the bucket capacity for token buckets
+	Limit int64
+	// Window is the sliding window length, or the time a token bucket takes
+	// to refill from empty to Limit
+	Window time.Duration
+}
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// RetryAfter is how long to wait until the request would be allowed;
+	// zero when it was allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the limiter is fully replenished
+	ResetAfter time.Duration
+}
+
+var (
+	rateLimitsMu sync.RWMutex
+	rateLimits   = map[string]RateLimit{
+		ClassRecipient: {Algorithm: AlgorithmSlidingWindow, Limit: 10, Window: time.Minute},
+		ClassProvider:  {Algorithm: AlgorithmTokenBucket, Limit: 50, Window: time.Second},
+		ClassGlobal:    {Algorithm: AlgorithmTokenBucket, Limit: 50, Window: time.Second},
+		ClassAPI:       {Algorithm: AlgorithmTokenBucket, Limit: 20, Window: time.Second},
+	}
+)
+
+// slidingWindowScript keeps a sorted set of event timestamps. Expired events
+// are trimmed, and new events are only recorded when they are allowed, so
+// denied requests never push the window further out.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local member = ARGV[4]
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+local retry_after = 0
+if count + cost <= limit then
+  for i = 1, cost do
+    redis.call('ZADD', key, now, member .. ':' .. i)
+  end
+  count = count + cost
+  allowed = 1
+elseif cost > limit then
+  retry_after = -1
+else
+  local oldest = redis.call('ZRANGE', key, count + cost - limit - 1, count + cost - limit - 1, 'WITHSCORES')
+  retry_after = tonumber(oldest[2]) + window - now
+end
+
+local reset_after = 0
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+if newest[2] then
+  reset_after = tonumber(newest[2]) + window - now
+  redis.call('PEXPIRE', key, reset_after)
+end
+
+return {allowed, limit - count, retry_after, reset_after}
+`)
+
+// tokenBucketScript stores the token count and the time it was last
+// refilled. Tokens are only taken when the request is allowed.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local refill = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local rate = capacity / refill
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry_after = 0
+if cost > capacity then
+  retry_after = -1
+elseif tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  retry_after = math.ceil((cost - tokens) / rate)
+end
+
+local reset_after = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, math.max(reset_after, 1))
+
+return {allowed, math.floor(tokens), retry_after, reset_after}
+`)
+
+// SetRateLimit configures the limiter for a class of keys
+func SetRateLimit(class string, limit RateLimit) {
+	rateLimitsMu.Lock()
+	defer rateLimitsMu.Unlock()
+	rateLimits[class] = limit
+}
+
+// GetRateLimit returns the limiter for a class of keys. RATE_LIMIT_<CLASS>
+// (e.g. RATE_LIMIT_RECIPIENT=sliding_window:10/1m) overrides the default.
+func GetRateLimit(class string) (RateLimit, error) {
+	envKey := "RATE_LIMIT_" + strings.ToUpper(class)
+	if value := getEnv(envKey, ""); value != "" {
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return RateLimit{}, fmt.Errorf("invalid %s: %v", envKey, err)
+		}
+		return limit, nil
+	}
+
+	rateLimitsMu.RLock()
+	defer rateLimitsMu.RUnlock()
+	limit, ok := rateLimits[class]
+	if !ok {
+		return RateLimit{}, fmt.Errorf("no rate limit configured for class %q", class)
+	}
+	return limit, nil
+}
+
+// ParseRateLimit parses "<algorithm>:<limit>/<window>", for example
+// "sliding_window:10/1m" or "token_bucket:50/1s"
+func ParseRateLimit(value string) (RateLimit, error) {
+	algorithm, spec, ok := strings.Cut(value, ":")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("expected <algorithm>:<limit>/<window>, got %q", value)
+	}
+	if algorithm != AlgorithmSlidingWindow && algorithm != AlgorithmTokenBucket {
+		return RateLimit{}, fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+
+	limitStr, windowStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("expected <limit>/<window>, got %q", spec)
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil || limit <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid limit %q", limitStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window < time.Millisecond {
+		return RateLimit{}, fmt.Errorf("invalid window %q", windowStr)
+	}
+
+	return RateLimit{Algorithm: algorithm, Limit: limit, Window: window}, nil
+}
+
+func rateLimitKey(class, key string) string {
+	return RateLimitPrefix + class + ":" + key
+}
+
+// Allow records one event for key under the limiter of its class
+func Allow(ctx context.Context, class, key string) (*RateLimitResult, error) {
+	return AllowN(ctx, class, key, 1)
+}
+
+// AllowN records n events at once; either all of them are allowed or none
+func AllowN(ctx context.Context, class, key string, n int64) (*RateLimitResult, error) {
+	limit, err := GetRateLimit(class)
+	if err != nil {
+		return nil, err
+	}
+	return allow(ctx, limit, class, key, n)
+}
+
+// AllowWithLimit records one event for key using an explicit limiter instead
+// of the one configured for its class, e.g. a limit set per provider
+func AllowWithLimit(ctx context.Context, limit RateLimit, class, key string) (*RateLimitResult, error) {
+	return allow(ctx, limit, class, key, 1)
+}
+
+func allow(ctx context.Context, limit RateLimit, class, key string, n int64) (*RateLimitResult, error) {
+	if key == "" {
+		return nil, fmt.Errorf("rate limit key cannot be empty")
+	}
+	if limit.Limit <= 0 || limit.Window < time.Millisecond {
+		return nil, fmt.Errorf("invalid rate limit %+v", limit)
+	}
+
+	var script *redis.Script
+	args := []interface{}{limit.Window.Milliseconds(), limit.Limit, n}
+	switch limit.Algorithm {
+	case AlgorithmSlidingWindow:
+		script = slidingWindowScript
+		args = append(args, uuid.New().String())
+	case AlgorithmTokenBucket:
+		script = tokenBucketScript
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+
+	var values []interface{}
+	err := withRetry(func() error {
+		var err error
+		values, err = script.Run(ctx, Client, []string{rateLimitKey(class, key)}, args...).Slice()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rate limit check failed: %v", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("rate limit check failed: unexpected script result %v", values)
+	}
+
+	result := &RateLimitResult{
+		Allowed:    values[0].(int64) == 1,
+		Limit:      limit.Limit,
+		Remaining:  values[1].(int64),
+		RetryAfter: time.Duration(values[2].(int64)) * time.Millisecond,
+		ResetAfter: time.Duration(values[3].(int64)) * time.Millisecond,
+	}
+	if result.RetryAfter < 0 {
+		return nil, fmt.Errorf("rate limit check failed: %d events can never fit a limit of %d", n, limit.Limit)
+	}
+	return result, nil
+}
+
+// ResetRateLimit clears the limiter state of a key
+func ResetRateLimit(ctx context.Context, class, key string) error {
+	if key == "" {
+		return fmt.Errorf("rate limit key cannot be empty")
+	}
+
+	return withRetry(func() error {
+		return Client.Del(ctx, rateLimitKey(class, key)).Err()
+	})
+}
+
+// CheckRateLimit records a message from a tenant to a recipient under the
+// recipient limiter. A non-nil limit replaces the configured one, e.g. a
+// tenant's own limit. Each tenant has its own allowance per recipient.
+func CheckRateLimit(ctx context.Context, tenantID uint, recipient string, limit *RateLimit) (*RateLimitResult, error) {
+	if recipient == "" {
+		return nil, fmt.Errorf("recipient cannot be empty")
+	}
+	if limit != nil {
+		return AllowWithLimit(ctx, *limit, ClassRecipient, tenantKey(tenantID, recipient))
+	}
+	return Allow(ctx, ClassRecipient, tenantKey(tenantID, recipient))
+}
+
+// ClearRateLimit clears a tenant's rate limit for a recipient with retries
+func ClearRateLimit(ctx context.Context, tenantID uint, recipient string) error {
+	if recipient == "" {
+		return fmt.Errorf("recipient cannot be empty")
+	}
+	return ResetRateLimit(ctx, ClassRecipient, tenantKey(tenantID, recipient))
+}
@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{
+			name:  "Sliding window",
+			value: "sliding_window:10/1m",
+			want:  RateLimit{Algorithm: AlgorithmSlidingWindow, Limit: 10, Window: time.Minute},
+		},
+		{
+			name:  "Token bucket",
+			value: "token_bucket:50/1s",
+			want:  RateLimit{Algorithm: AlgorithmTokenBucket, Limit: 50, Window: time.Second},
+		},
+		{
+			name:    "Unknown algorithm",
+			value:   "fixed_window:10/1m",
+			wantErr: true,
+		},
+		{
+			name:    "Missing window",
+			value:   "sliding_window:10",
+			wantErr: true,
+		},
+		{
+			name:    "Zero limit",
+			value:   "token_bucket:0/1s",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid window",
+			value:   "token_bucket:5/soon",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetRateLimit(t *testing.T) {
+	limit, err := GetRateLimit(ClassRecipient)
+	assert.NoError(t, err)
+	assert.Equal(t, RateLimit{Algorithm: AlgorithmSlidingWindow, Limit: 10, Window: time.Minute}, limit)
+
+	t.Setenv("RATE_LIMIT_RECIPIENT", "token_bucket:5/1s")
+	limit, err = GetRateLimit(ClassRecipient)
+	assert.NoError(t, err)
+	assert.Equal(t, RateLimit{Algorithm: AlgorithmTokenBucket, Limit: 5, Window: time.Second}, limit)
+
+	_, err = GetRateLimit("unknown")
+	assert.Error(t, err)
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	setupLocalRedis(t)
+
+	ctx := context.Background()
+	recipient := "+905551234567"
+
+	// Clear any existing rate limit
+	if err := ClearRateLimit(ctx, 1, recipient); err != nil {
+		t.Fatalf("Failed to clear rate limit: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		recipient  string
+		iterations int
+		want       bool
+	}{
+		{
+			name:       "Under rate limit",
+			recipient:  recipient,
+			iterations: 5,
+			want:       true,
+		},
+		{
+			name:       "Exceed rate limit",
+			recipient:  recipient,
+			iterations: 15,
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clear rate limit before each test
+			assert.NoError(t, ClearRateLimit(ctx, 1, tt.recipient))
+
+			var lastResult *RateLimitResult
+			for i := 0; i < tt.iterations; i++ {
+				var err error
+				lastResult, err = CheckRateLimit(ctx, 1, tt.recipient, nil)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, lastResult.Allowed)
+		})
+	}
+}
+
+func TestClearRateLimit(t *testing.T) {
+	setupLocalRedis(t)
+
+	ctx := context.Background()
+	recipient := "+905551234567"
+
+	tests := []struct {
+		name      string
+		recipient string
+		wantErr   bool
+	}{
+		{
+			name:      "Successfully clear rate limit",
+			recipient: recipient,
+			wantErr:   false,
+		},
+		{
+			name:      "Clear non-existent rate limit",
+			recipient: "non-existent",
+			wantErr:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set a rate limit first
+			_, err := CheckRateLimit(ctx, 1, tt.recipient, nil)
+			assert.NoError(t, err)
+
+			// Clear the rate limit
+			err = ClearRateLimit(ctx, 1, tt.recipient)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+
+				// Verify rate limit was cleared
+				result, err := CheckRateLimit(ctx, 1, tt.recipient, nil)
+				assert.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, int64(9), result.Remaining)
+			}
+		})
+	}
+}
+
+func TestAllowSlidingWindow(t *testing.T) {
+	setupLocalRedis(t)
+
+	ctx := context.Background()
+	SetRateLimit("test_sliding", RateLimit{Algorithm: AlgorithmSlidingWindow, Limit: 3, Window: time.Minute})
+	assert.NoError(t, ResetRateLimit(ctx, "test_sliding", "key"))
+
+	for i := int64(2); i >= 0; i-- {
+		result, err := Allow(ctx, "test_sliding", "key")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, i, result.Remaining)
+		assert.Zero(t, result.RetryAfter)
+	}
+
+	// Denied requests report when to retry and are not recorded
+	for i := 0; i < 3; i++ {
+		result, err := Allow(ctx, "test_sliding", "key")
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Greater(t, result.RetryAfter, 50*time.Second)
+		assert.LessOrEqual(t, result.RetryAfter, time.Minute)
+	}
+	count, err := Client.ZCard(ctx, rateLimitKey("test_sliding", "key")).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// The key always carries a TTL
+	ttl, err := Client.PTTL(ctx, rateLimitKey("test_sliding", "key")).Result()
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	assert.NoError(t, ResetRateLimit(ctx, "test_sliding", "key"))
+}
+
+func TestAllowTokenBucket(t *testing.T) {
+	setupLocalRedis(t)
+
+	ctx := context.Background()
+	SetRateLimit("test_bucket", RateLimit{Algorithm: AlgorithmTokenBucket, Limit: 2, Window: 200 * time.Millisecond})
+	assert.NoError(t, ResetRateLimit(ctx, "test_bucket", "key"))
+
+	for i := 0; i < 2; i++ {
+		result, err := Allow(ctx, "test_bucket", "key")
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+
+	result, err := Allow(ctx, "test_bucket", "key")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, result.RetryAfter, 100*time.Millisecond)
+
+	// Tokens refill over time
+	time.Sleep(result.RetryAfter + 10*time.Millisecond)
+	result, err = Allow(ctx, "test_bucket", "key")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// More events than the bucket can ever hold is an error
+	_, err = AllowN(ctx, "test_bucket", "key", 3)
+	assert.Error(t, err)
+
+	assert.NoError(t, ResetRateLimit(ctx, "test_bucket", "key"))
+}
+
+func TestAllowWithLimit(t *testing.T) {
+	setupLocalRedis(t)
+
+	ctx := context.Background()
+	limit := RateLimit{Algorithm: AlgorithmTokenBucket, Limit: 1, Window: time.Minute}
+	assert.NoError(t, ResetRateLimit(ctx, ClassProvider, "test-provider"))
+
+	result, err := AllowWithLimit(ctx, limit, ClassProvider, "test-provider")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, int64(1), result.Limit)
+
+	result, err = AllowWithLimit(ctx, limit, ClassProvider, "test-provider")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	_, err = AllowWithLimit(ctx, RateLimit{Algorithm: AlgorithmTokenBucket}, ClassProvider, "test-provider")
+	assert.Error(t, err)
+
+	assert.NoError(t, ResetRateLimit(ctx, ClassProvider, "test-provider"))
+}
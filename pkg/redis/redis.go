@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -14,11 +15,13 @@ import (
 )
 
 const (
-	MessageKeyPrefix = "message:"
-	RateLimitPrefix  = "rate_limit:"
-	CacheDuration    = 24 * time.Hour
-	MaxRetries       = 3
-	PoolSize         = 10
+	MessageKeyPrefix     = "message:"
+	RateLimitPrefix      = "rate_limit:"
+	SuppressionKeyPrefix = "suppression:"
+	CacheDuration        = 24 * time.Hour
+	SuppressionCacheTTL  = 10 * time.Minute
+	MaxRetries           = 3
+	PoolSize             = 10
 )
 
 var (
@@ -42,6 +45,8 @@ func InitRedis() error {
 			MinIdleConns: 2,
 			MaxRetries:   MaxRetries,
 		})
+		Client.AddHook(metricsHook{})
+		Client.AddHook(tracingHook{})
 
 		ctx := context.Background()
 		if err := Client.Ping(ctx).Err(); err != nil {
@@ -59,6 +64,16 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// tenantKey scopes a key to a tenant so tenants never share cache entries
+// or limiter state
+func tenantKey(tenantID uint, key string) string {
+	return strconv.FormatUint(uint64(tenantID), 10) + ":" + key
+}
+
+func messageKey(tenantID uint, messageID string) string {
+	return MessageKeyPrefix + tenantKey(tenantID, messageID)
+}
+
 // withRetry executes a Redis operation with retries
 func withRetry(operation func() error) error {
 	var err error
@@ -82,19 +97,19 @@ func CacheMessage(ctx context.Context, msg *models.Message) error {
 		return fmt.Errorf("failed to marshal message: %v", err)
 	}
 
-	key := MessageKeyPrefix + msg.MessageID
+	key := messageKey(msg.TenantID, msg.MessageID)
 	return withRetry(func() error {
 		return Client.Set(ctx, key, string(data), CacheDuration).Err()
 	})
 }
 
-// GetCachedMessage retrieves a message from Redis cache with retries
-func GetCachedMessage(ctx context.Context, messageID string) (*models.Message, error) {
+// GetCachedMessage retrieves a tenant's message from Redis cache with retries
+func GetCachedMessage(ctx context.Context, tenantID uint, messageID string) (*models.Message, error) {
 	if messageID == "" {
 		return nil, fmt.Errorf("messageID cannot be empty")
 	}
 
-	key := MessageKeyPrefix + messageID
+	key := messageKey(tenantID, messageID)
 	var data string
 	err := withRetry(func() error {
 		var err error
@@ -119,41 +134,71 @@ func GetCachedMessage(ctx context.Context, messageID string) (*models.Message, e
 	return &msg, nil
 }
 
-// CheckRateLimit checks if we can send more messages with retries
-func CheckRateLimit(ctx context.Context, recipient string) (bool, error) {
+// Publish sends a payload to a Redis pub/sub channel with retries
+func Publish(ctx context.Context, channel string, payload string) error {
+	if channel == "" {
+		return fmt.Errorf("channel cannot be empty")
+	}
+
+	return withRetry(func() error {
+		return Client.Publish(ctx, channel, payload).Err()
+	})
+}
+
+// CacheSuppression stores whether a recipient is suppressed for a tenant. The
+// entry lives for at most SuppressionCacheTTL, or less when ttl is shorter.
+func CacheSuppression(ctx context.Context, tenantID uint, recipient string, suppressed bool, ttl time.Duration) error {
 	if recipient == "" {
-		return false, fmt.Errorf("recipient cannot be empty")
+		return fmt.Errorf("recipient cannot be empty")
+	}
+	if ttl <= 0 || ttl > SuppressionCacheTTL {
+		ttl = SuppressionCacheTTL
 	}
 
-	key := RateLimitPrefix + recipient
-	var count int64
-	err := withRetry(func() error {
-		var err error
-		count, err = Client.Incr(ctx, key).Result()
-		if err != nil {
-			return err
-		}
+	value := "0"
+	if suppressed {
+		value = "1"
+	}
+	key := SuppressionKeyPrefix + tenantKey(tenantID, recipient)
+	return withRetry(func() error {
+		return Client.Set(ctx, key, value, ttl).Err()
+	})
+}
+
+// GetCachedSuppression returns the cached suppression state of a recipient
+// for a tenant. found is false when nothing is cached.
+func GetCachedSuppression(ctx context.Context, tenantID uint, recipient string) (suppressed bool, found bool, err error) {
+	if recipient == "" {
+		return false, false, fmt.Errorf("recipient cannot be empty")
+	}
 
-		if count == 1 {
-			return Client.Expire(ctx, key, time.Minute).Err()
+	key := SuppressionKeyPrefix + tenantKey(tenantID, recipient)
+	var data string
+	err = withRetry(func() error {
+		var err error
+		data, err = Client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return nil
 		}
-		return nil
+		return err
 	})
-
 	if err != nil {
-		return false, fmt.Errorf("rate limit check failed: %v", err)
+		return false, false, err
 	}
-
-	return count <= 10, nil
+	if data == "" {
+		return false, false, nil
+	}
+	return data == "1", true, nil
 }
 
-// ClearRateLimit clears the rate limit for a recipient with retries
-func ClearRateLimit(ctx context.Context, recipient string) error {
+// ClearSuppression removes the cached suppression state of a recipient for a
+// tenant
+func ClearSuppression(ctx context.Context, tenantID uint, recipient string) error {
 	if recipient == "" {
 		return fmt.Errorf("recipient cannot be empty")
 	}
 
-	key := RateLimitPrefix + recipient
+	key := SuppressionKeyPrefix + tenantKey(tenantID, recipient)
 	return withRetry(func() error {
 		return Client.Del(ctx, key).Err()
 	})
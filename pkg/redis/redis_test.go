@@ -5,10 +5,23 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/vkukul/messaging-system/internal/models"
 )
 
+// setupLocalRedis points Client at an in-memory Redis for the test
+func setupLocalRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	previous := Client
+	Client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		Client.Close()
+		Client = previous
+	})
+}
+
 func TestInitRedis(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -33,10 +46,7 @@ func TestInitRedis(t *testing.T) {
 }
 
 func TestCacheMessage(t *testing.T) {
-	// Initialize Redis for tests
-	if err := InitRedis(); err != nil {
-		t.Fatalf("Failed to initialize Redis: %v", err)
-	}
+	setupLocalRedis(t)
 
 	ctx := context.Background()
 	tests := []struct {
@@ -72,7 +82,7 @@ func TestCacheMessage(t *testing.T) {
 				assert.NoError(t, err)
 
 				// Verify the message was cached correctly
-				cached, err := GetCachedMessage(ctx, tt.msg.MessageID)
+				cached, err := GetCachedMessage(ctx, tt.msg.TenantID, tt.msg.MessageID)
 				assert.NoError(t, err)
 				assert.NotNil(t, cached)
 				assert.Equal(t, tt.msg.ID, cached.ID)
@@ -84,10 +94,7 @@ func TestCacheMessage(t *testing.T) {
 }
 
 func TestGetCachedMessage(t *testing.T) {
-	// Initialize Redis for tests
-	if err := InitRedis(); err != nil {
-		t.Fatalf("Failed to initialize Redis: %v", err)
-	}
+	setupLocalRedis(t)
 
 	ctx := context.Background()
 	testMsg := &models.Message{
@@ -126,7 +133,7 @@ func TestGetCachedMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetCachedMessage(ctx, tt.messageID)
+			got, err := GetCachedMessage(ctx, testMsg.TenantID, tt.messageID)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -144,100 +151,45 @@ func TestGetCachedMessage(t *testing.T) {
 	}
 }
 
-func TestCheckRateLimit(t *testing.T) {
-	// Initialize Redis for tests
-	if err := InitRedis(); err != nil {
-		t.Fatalf("Failed to initialize Redis: %v", err)
-	}
+func TestCacheSuppression(t *testing.T) {
+	setupLocalRedis(t)
 
 	ctx := context.Background()
 	recipient := "+905551234567"
 
-	// Clear any existing rate limit
-	if err := ClearRateLimit(ctx, recipient); err != nil {
-		t.Fatalf("Failed to clear rate limit: %v", err)
-	}
-
 	tests := []struct {
 		name       string
-		recipient  string
-		iterations int
-		want       bool
+		suppressed bool
 	}{
 		{
-			name:       "Under rate limit",
-			recipient:  recipient,
-			iterations: 5,
-			want:       true,
+			name:       "Cache suppressed recipient",
+			suppressed: true,
 		},
 		{
-			name:       "Exceed rate limit",
-			recipient:  recipient,
-			iterations: 15,
-			want:       false,
+			name:       "Cache allowed recipient",
+			suppressed: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Clear rate limit before each test
-			assert.NoError(t, ClearRateLimit(ctx, tt.recipient))
+			assert.NoError(t, CacheSuppression(ctx, 1, recipient, tt.suppressed, time.Minute))
 
-			var lastResult bool
-			for i := 0; i < tt.iterations; i++ {
-				var err error
-				lastResult, err = CheckRateLimit(ctx, tt.recipient)
-				assert.NoError(t, err)
-			}
-			assert.Equal(t, tt.want, lastResult)
+			suppressed, found, err := GetCachedSuppression(ctx, 1, recipient)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tt.suppressed, suppressed)
 		})
 	}
-}
-
-func TestClearRateLimit(t *testing.T) {
-	// Initialize Redis for tests
-	if err := InitRedis(); err != nil {
-		t.Fatalf("Failed to initialize Redis: %v", err)
-	}
-
-	ctx := context.Background()
-	recipient := "+905551234567"
-
-	tests := []struct {
-		name      string
-		recipient string
-		wantErr   bool
-	}{
-		{
-			name:      "Successfully clear rate limit",
-			recipient: recipient,
-			wantErr:   false,
-		},
-		{
-			name:      "Clear non-existent rate limit",
-			recipient: "non-existent",
-			wantErr:   false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Set a rate limit first
-			_, err := CheckRateLimit(ctx, tt.recipient)
-			assert.NoError(t, err)
 
-			// Clear the rate limit
-			err = ClearRateLimit(ctx, tt.recipient)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
+	// Entries are kept per tenant
+	_, found, err := GetCachedSuppression(ctx, 2, recipient)
+	assert.NoError(t, err)
+	assert.False(t, found)
 
-				// Verify rate limit was cleared
-				count, err := CheckRateLimit(ctx, tt.recipient)
-				assert.NoError(t, err)
-				assert.True(t, count)
-			}
-		})
-	}
+	// Clearing removes the entry
+	assert.NoError(t, ClearSuppression(ctx, 1, recipient))
+	_, found, err = GetCachedSuppression(ctx, 1, recipient)
+	assert.NoError(t, err)
+	assert.False(t, found)
 }
@@ -0,0 +1,41 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign and rejects timestamps that are
+// further than tolerance away from now
+func Verify(secret string, timestamp int64, body []byte, signature string, tolerance time.Duration) error {
+	if secret == "" {
+		return fmt.Errorf("signing secret cannot be empty")
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age < 0 {
+		age = -age
+	}
+	if tolerance > 0 && age > tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance")
+	}
+
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
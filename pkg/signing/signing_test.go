@@ -0,0 +1,71 @@
+package signing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"to":"+905551234567"}`)
+
+	first := Sign("secret", 1700000000, body)
+	assert.Len(t, first, 64)
+	assert.Equal(t, first, Sign("secret", 1700000000, body))
+	assert.NotEqual(t, first, Sign("other", 1700000000, body))
+	assert.NotEqual(t, first, Sign("secret", 1700000001, body))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"to":"+905551234567"}`)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		signature string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid signature",
+			secret:    "secret",
+			timestamp: now,
+			signature: Sign("secret", now, body),
+			wantErr:   false,
+		},
+		{
+			name:      "Wrong secret",
+			secret:    "other",
+			timestamp: now,
+			signature: Sign("secret", now, body),
+			wantErr:   true,
+		},
+		{
+			name:      "Stale timestamp",
+			secret:    "secret",
+			timestamp: now - 600,
+			signature: Sign("secret", now-600, body),
+			wantErr:   true,
+		},
+		{
+			name:      "Empty secret",
+			secret:    "",
+			timestamp: now,
+			signature: Sign("", now, body),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, body, tt.signature, 5*time.Minute)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
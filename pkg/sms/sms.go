@@ -0,0 +1,143 @@
+// Package sms works out how a text is encoded and split into SMS segments.
+//
+// Text made only of GSM 03.38 characters is sent as GSM-7, where a single
+// SMS holds 160 septets and characters from the extension table (such as
+// € or {) take two. Any other character makes the whole text UCS-2, where a
+// single SMS holds 70 UTF-16 code units. Longer texts are sent as a
+// concatenated SMS whose segments lose room to the concatenation header:
+// 153 septets or 67 code units each.
+package sms
+
+import (
+	"unicode/utf16"
+)
+
+// Encoding is the character set a text is sent in
+type Encoding string
+
+const (
+	GSM7 Encoding = "GSM-7"
+	UCS2 Encoding = "UCS-2"
+)
+
+const (
+	gsm7SingleLimit  = 160
+	gsm7SegmentLimit = 153
+	ucs2SingleLimit  = 70
+	ucs2SegmentLimit = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet without the escape character
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds the characters sent as an escape plus one septet
+const gsm7Extension = "\f^{}\\[~]|€"
+
+var (
+	basicSet     = runeSet(gsm7Basic)
+	extensionSet = runeSet(gsm7Extension)
+)
+
+// Info describes how a text is sent
+type Info struct {
+	Encoding Encoding
+	// Units is the length in septets for GSM-7 or UTF-16 code units for UCS-2
+	Units int
+	// Segments is how many SMS the text is sent as; an empty text is one
+	Segments int
+}
+
+// Detect returns the encoding a text is sent in
+func Detect(text string) Encoding {
+	for _, r := range text {
+		if !basicSet[r] && !extensionSet[r] {
+			return UCS2
+		}
+	}
+	return GSM7
+}
+
+// Analyze returns a text's encoding, length and segment count
+func Analyze(text string) Info {
+	encoding := Detect(text)
+	info := Info{Encoding: encoding, Segments: 1}
+	for _, r := range text {
+		info.Units += width(r, encoding)
+	}
+	if info.Units > singleLimit(encoding) {
+		info.Segments = len(boundaries(text, encoding)) + 1
+	}
+	return info
+}
+
+// Split returns the text of each segment a text is sent as. Characters
+// taking two units are never split across segments.
+func Split(text string) []string {
+	info := Analyze(text)
+	if info.Segments == 1 {
+		return []string{text}
+	}
+
+	segments := make([]string, 0, info.Segments)
+	start := 0
+	for _, end := range boundaries(text, info.Encoding) {
+		segments = append(segments, text[start:end])
+		start = end
+	}
+	return append(segments, text[start:])
+}
+
+// boundaries returns the byte offsets where each segment after the first
+// starts when a text is sent as a concatenated SMS
+func boundaries(text string, encoding Encoding) []int {
+	limit := segmentLimit(encoding)
+	var offsets []int
+	used := 0
+	for i, r := range text {
+		w := width(r, encoding)
+		if used+w > limit {
+			offsets = append(offsets, i)
+			used = 0
+		}
+		used += w
+	}
+	return offsets
+}
+
+// width is how many units a character takes in an encoding
+func width(r rune, encoding Encoding) int {
+	if encoding == UCS2 {
+		// Characters outside the Basic Multilingual Plane need a surrogate pair
+		if utf16.IsSurrogate(r) || r <= 0xFFFF {
+			return 1
+		}
+		return 2
+	}
+	if extensionSet[r] {
+		return 2
+	}
+	return 1
+}
+
+func singleLimit(encoding Encoding) int {
+	if encoding == UCS2 {
+		return ucs2SingleLimit
+	}
+	return gsm7SingleLimit
+}
+
+func segmentLimit(encoding Encoding) int {
+	if encoding == UCS2 {
+		return ucs2SegmentLimit
+	}
+	return gsm7SegmentLimit
+}
+
+func runeSet(chars string) map[rune]bool {
+	set := make(map[rune]bool)
+	for _, r := range chars {
+		set[r] = true
+	}
+	return set
+}
@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantEncoding Encoding
+		wantUnits    int
+		wantSegments int
+	}{
+		{name: "Empty", text: "", wantEncoding: GSM7, wantUnits: 0, wantSegments: 1},
+		{name: "Plain GSM-7", text: "Hello, world!", wantEncoding: GSM7, wantUnits: 13, wantSegments: 1},
+		{name: "GSM-7 accents", text: "Çà é ñ Ø", wantEncoding: GSM7, wantUnits: 8, wantSegments: 1},
+		{name: "Extension characters count double", text: "Price: 5€ {x}", wantEncoding: GSM7, wantUnits: 16, wantSegments: 1},
+		{name: "Full single GSM-7", text: strings.Repeat("a", 160), wantEncoding: GSM7, wantUnits: 160, wantSegments: 1},
+		{name: "Two GSM-7 segments", text: strings.Repeat("a", 161), wantEncoding: GSM7, wantUnits: 161, wantSegments: 2},
+		{name: "Three GSM-7 segments", text: strings.Repeat("a", 307), wantEncoding: GSM7, wantUnits: 307, wantSegments: 3},
+		{name: "Turkish letters need UCS-2", text: "Merhaba ş", wantEncoding: UCS2, wantUnits: 9, wantSegments: 1},
+		{name: "Full single UCS-2", text: strings.Repeat("ş", 70), wantEncoding: UCS2, wantUnits: 70, wantSegments: 1},
+		{name: "Two UCS-2 segments", text: strings.Repeat("ş", 71), wantEncoding: UCS2, wantUnits: 71, wantSegments: 2},
+		{name: "Emoji take two code units", text: "Hi 👋", wantEncoding: UCS2, wantUnits: 5, wantSegments: 1},
+		{name: "Escape character is not GSM-7 text", text: "\x1b", wantEncoding: UCS2, wantUnits: 1, wantSegments: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Analyze(tt.text)
+			assert.Equal(t, tt.wantEncoding, info.Encoding)
+			assert.Equal(t, tt.wantUnits, info.Units)
+			assert.Equal(t, tt.wantSegments, info.Segments)
+			assert.Len(t, Split(tt.text), tt.wantSegments)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	text := strings.Repeat("a", 200)
+	segments := Split(text)
+	assert.Equal(t, []string{strings.Repeat("a", 153), strings.Repeat("a", 47)}, segments)
+
+	// An extension character is not split from its escape
+	text = strings.Repeat("a", 152) + "€" + strings.Repeat("b", 10)
+	segments = Split(text)
+	assert.Equal(t, []string{strings.Repeat("a", 152), "€" + strings.Repeat("b", 10)}, segments)
+	assert.Equal(t, 2, Analyze(text).Segments)
+
+	// Nor is a surrogate pair
+	text = strings.Repeat("ş", 66) + "👋" + strings.Repeat("ş", 5)
+	segments = Split(text)
+	assert.Equal(t, []string{strings.Repeat("ş", 66), "👋" + strings.Repeat("ş", 5)}, segments)
+
+	for _, text := range []string{strings.Repeat("x€", 200), strings.Repeat("ğü👋", 90)} {
+		assert.Equal(t, text, strings.Join(Split(text), ""))
+	}
+}